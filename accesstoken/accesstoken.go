@@ -0,0 +1,166 @@
+// Package accesstoken mints Twilio access tokens (JWTs) that let browser and
+// mobile clients use the Twilio Voice SDK.
+package accesstoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/phonenumber"
+)
+
+// Token lifetimes.
+const (
+	// DefaultTTL is the token lifetime when AccessToken.TTL is zero.
+	DefaultTTL = time.Hour
+
+	// MaxTTL is the longest lifetime Twilio accepts.
+	MaxTTL = 24 * time.Hour
+)
+
+// VoiceGrant allows a client to make and receive calls.
+type VoiceGrant struct {
+	// OutgoingApplicationSID is the TwiML application (AP...) that handles
+	// calls the client places. Empty disables outgoing calls.
+	OutgoingApplicationSID string
+
+	// OutgoingApplicationParams are passed to the TwiML application with
+	// each outgoing call.
+	OutgoingApplicationParams map[string]string
+
+	// IncomingAllow lets the client receive calls to client:<identity>.
+	IncomingAllow bool
+
+	// PushCredentialSID enables push notifications for incoming calls on
+	// mobile clients.
+	PushCredentialSID string
+}
+
+// AccessToken is a Twilio access token, signed with an API key.
+type AccessToken struct {
+	// AccountSID is the account (AC...) the token is for.
+	AccountSID string
+
+	// APIKeySID (SK...) and APIKeySecret sign the token.
+	APIKeySID    string
+	APIKeySecret string
+
+	// Identity names the client; calls to client:<identity> reach it.
+	Identity string
+
+	// TTL is the token lifetime. Defaults to DefaultTTL; at most MaxTTL.
+	TTL time.Duration
+
+	// NotBefore delays when the token becomes valid. Optional.
+	NotBefore time.Time
+
+	// Voice is the voice grant.
+	Voice *VoiceGrant
+}
+
+type header struct {
+	Type        string `json:"typ"`
+	Algorithm   string `json:"alg"`
+	ContentType string `json:"cty"`
+}
+
+type claims struct {
+	ID        string `json:"jti"`
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+	Grants    grants `json:"grants"`
+}
+
+type grants struct {
+	Identity string      `json:"identity,omitempty"`
+	Voice    *voiceGrant `json:"voice,omitempty"`
+}
+
+type voiceGrant struct {
+	Incoming          *incomingGrant `json:"incoming,omitempty"`
+	Outgoing          *outgoingGrant `json:"outgoing,omitempty"`
+	PushCredentialSID string         `json:"push_credential_sid,omitempty"`
+}
+
+type incomingGrant struct {
+	Allow bool `json:"allow"`
+}
+
+type outgoingGrant struct {
+	ApplicationSID string            `json:"application_sid"`
+	Params         map[string]string `json:"params,omitempty"`
+}
+
+// JWT returns the signed token.
+func (t *AccessToken) JWT() (string, error) {
+	if t.AccountSID == "" || t.APIKeySID == "" || t.APIKeySecret == "" {
+		return "", fmt.Errorf("account SID, API key SID and API key secret are required")
+	}
+	if t.Voice != nil && t.Identity == "" {
+		return "", fmt.Errorf("identity is required for a voice grant")
+	}
+	if t.Identity != "" && !phonenumber.ValidClientIdentity(t.Identity) {
+		return "", fmt.Errorf("invalid client identity %q", t.Identity)
+	}
+
+	ttl := t.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if ttl > MaxTTL {
+		return "", fmt.Errorf("token TTL %s exceeds %s", ttl, MaxTTL)
+	}
+
+	now := time.Now()
+
+	c := claims{
+		ID:        fmt.Sprintf("%s-%d", t.APIKeySID, now.Unix()),
+		Issuer:    t.APIKeySID,
+		Subject:   t.AccountSID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		Grants:    grants{Identity: t.Identity},
+	}
+	if !t.NotBefore.IsZero() {
+		c.NotBefore = t.NotBefore.Unix()
+	}
+	if v := t.Voice; v != nil {
+		grant := &voiceGrant{PushCredentialSID: v.PushCredentialSID}
+		if v.IncomingAllow {
+			grant.Incoming = &incomingGrant{Allow: true}
+		}
+		if v.OutgoingApplicationSID != "" {
+			grant.Outgoing = &outgoingGrant{
+				ApplicationSID: v.OutgoingApplicationSID,
+				Params:         v.OutgoingApplicationParams,
+			}
+		}
+		c.Grants.Voice = grant
+	}
+
+	h, err := json.Marshal(header{Type: "JWT", Algorithm: "HS256", ContentType: "twilio-fpa;v=1"})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode token header: %w", err)
+	}
+	p, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode token claims: %w", err)
+	}
+
+	signingInput := encode(h) + "." + encode(p)
+	mac := hmac.New(sha256.New, []byte(t.APIKeySecret))
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + encode(mac.Sum(nil)), nil
+}
+
+// encode returns unpadded base64url.
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,306 @@
+package callsystem
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	twilio "github.com/agentplexus/omnivoice-twilio"
+	"github.com/agentplexus/omnivoice-twilio/internal/audio"
+	"github.com/agentplexus/omnivoice-twilio/tracing"
+	"github.com/agentplexus/omnivoice-twilio/transport"
+	"github.com/agentplexus/omnivoice/agent"
+	omnitransport "github.com/agentplexus/omnivoice/transport"
+)
+
+// AgentPipelineConfig configures how Call.AttachAgent connects a call's
+// Media Stream to an agent session.
+type AgentPipelineConfig struct {
+	// Encoding is the audio encoding the session sends and receives:
+	// twilio.AudioEncodingMulaw (default, passed through unchanged) or
+	// twilio.AudioEncodingPCM (16-bit little-endian).
+	Encoding string
+
+	// SampleRate is the PCM sample rate the session uses. Defaults to
+	// twilio.DefaultSampleRate. Ignored for μ-law.
+	SampleRate int
+
+	// DTMFText formats DTMF input passed to the session via SendText.
+	// Completed sequences are sent when the stream collects them
+	// (transport.Connection.CollectDTMF), otherwise individual digits.
+	// Defaults to "DTMF: <digits>".
+	DTMFText func(digits string) string
+
+	// DisableDTMF stops DTMF input from being sent to the session.
+	DisableDTMF bool
+}
+
+// WithAgentPipeline configures the audio pipeline used by Call.AttachAgent.
+func WithAgentPipeline(config AgentPipelineConfig) Option {
+	return func(o *options) {
+		o.agentPipeline = config
+	}
+}
+
+// withDefaults fills in unset fields.
+func (c AgentPipelineConfig) withDefaults() AgentPipelineConfig {
+	if c.Encoding == "" {
+		c.Encoding = twilio.AudioEncodingMulaw
+	}
+	if c.SampleRate <= 0 {
+		c.SampleRate = twilio.DefaultSampleRate
+	}
+	if c.DTMFText == nil {
+		c.DTMFText = func(digits string) string { return "DTMF: " + digits }
+	}
+	return c
+}
+
+// validate checks the pipeline configuration.
+func (c AgentPipelineConfig) validate() error {
+	switch c.Encoding {
+	case "", twilio.AudioEncodingMulaw, twilio.AudioEncodingPCM:
+		return nil
+	default:
+		return fmt.Errorf("unsupported agent audio encoding %q", c.Encoding)
+	}
+}
+
+// agentPipeline pumps audio, barge-in and DTMF between a Media Stream and
+// an agent session until stopped.
+type agentPipeline struct {
+	call    *Call
+	session agent.Session
+	conn    omnitransport.Connection
+	config  AgentPipelineConfig
+
+	stop      chan struct{}
+	readCtx   context.Context // canceled when the pipeline closes
+	cancel    context.CancelFunc
+	stopOnce  sync.Once
+	unobserve func()
+	span      tracing.Span
+
+	// Resamplers for PCM sessions, used only by pumpInbound and
+	// pumpOutbound respectively
+	inbound  *audio.Resampler
+	outbound *audio.Resampler
+}
+
+// newAgentPipeline starts pumping between conn and session, traced by a
+// span that is a child of the span in ctx.
+func newAgentPipeline(ctx context.Context, call *Call, session agent.Session, conn omnitransport.Connection, config AgentPipelineConfig) *agentPipeline {
+	_, span := call.provider.tracer.Start(ctx, tracing.SpanAgent)
+	span.SetAttribute(tracing.AttrCallSID, call.id)
+
+	pl := &agentPipeline{
+		call:    call,
+		session: session,
+		conn:    conn,
+		config:  config,
+		stop:    make(chan struct{}),
+		span:    span,
+	}
+	pl.readCtx, pl.cancel = context.WithCancel(context.Background())
+	if config.Encoding == twilio.AudioEncodingPCM {
+		pl.inbound = audio.NewResampler(twilio.DefaultSampleRate, config.SampleRate)
+		pl.outbound = audio.NewResampler(config.SampleRate, twilio.DefaultSampleRate)
+	}
+
+	if tc, ok := conn.(*transport.Connection); ok && !config.DisableDTMF {
+		pl.unobserve = tc.OnEvent(func(event omnitransport.Event) {
+			pl.forwardDTMF(tc, event)
+		})
+	}
+
+	go pl.pumpInbound()
+	go pl.pumpOutbound()
+	go pl.pumpEvents()
+
+	return pl
+}
+
+// close stops the pipeline. It does not stop the session or connection.
+func (pl *agentPipeline) close() {
+	pl.stopOnce.Do(func() {
+		close(pl.stop)
+		pl.cancel()
+		if pl.unobserve != nil {
+			pl.unobserve()
+		}
+		pl.span.End()
+	})
+}
+
+// stopped reports whether the pipeline has been closed.
+func (pl *agentPipeline) stopped() bool {
+	select {
+	case <-pl.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// pumpInbound sends caller audio to the session. Reads from Twilio
+// connections stop when the pipeline closes, leaving unread audio for the
+// next pipeline.
+func (pl *agentPipeline) pumpInbound() {
+	read := pl.conn.AudioOut().Read
+	if tc, ok := pl.conn.(*transport.Connection); ok {
+		read = func(p []byte) (int, error) { return tc.ReadAudio(pl.readCtx, p) }
+	}
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := read(buf)
+		if pl.stopped() {
+			return
+		}
+		if n > 0 {
+			_ = pl.session.SendAudio(pl.fromTwilio(buf[:n]))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// pumpOutbound plays session audio to the caller.
+func (pl *agentPipeline) pumpOutbound() {
+	var carry []byte
+	for {
+		select {
+		case <-pl.stop:
+			return
+		case data, ok := <-pl.session.ReceiveAudio():
+			if !ok {
+				return
+			}
+			if len(data) > 0 {
+				pl.call.noteAgentOutput()
+			}
+			var frame []byte
+			frame, carry = pl.toTwilio(append(carry, data...))
+			if len(frame) == 0 {
+				continue
+			}
+			if _, err := pl.conn.AudioIn().Write(frame); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// pumpEvents propagates barge-in to the stream and relays session events.
+func (pl *agentPipeline) pumpEvents() {
+	for {
+		select {
+		case <-pl.stop:
+			return
+		case event, ok := <-pl.session.Events():
+			if !ok {
+				return
+			}
+
+			pl.call.provider.publish(EventAgentEvent, pl.call, event)
+
+			switch event.Type {
+			case agent.EventInterruption:
+				pl.span.AddEvent(string(event.Type))
+				// Stop playing agent audio the caller talked over
+				if tc, ok := pl.conn.(*transport.Connection); ok {
+					_ = tc.Clear()
+				}
+			case agent.EventSessionEnded:
+				pl.span.AddEvent(string(event.Type))
+				pl.close()
+				return
+			}
+		}
+	}
+}
+
+// forwardDTMF passes DTMF input to the session as text.
+func (pl *agentPipeline) forwardDTMF(conn *transport.Connection, event omnitransport.Event) {
+	if pl.stopped() {
+		return
+	}
+
+	var digits string
+	switch event.Type {
+	case omnitransport.EventDTMF:
+		if conn.CollectingDTMF() {
+			return
+		}
+		digits, _ = event.Data.(string)
+	case transport.EventDTMFSequence:
+		if seq, ok := event.Data.(*transport.DTMFSequence); ok {
+			digits = seq.Digits
+		}
+	}
+
+	if digits != "" {
+		_ = pl.session.SendText(pl.config.DTMFText(digits))
+	}
+}
+
+// fromTwilio converts μ-law 8 kHz audio to the session format.
+func (pl *agentPipeline) fromTwilio(data []byte) []byte {
+	if pl.config.Encoding == twilio.AudioEncodingMulaw {
+		return append([]byte(nil), data...)
+	}
+	samples := pl.inbound.Process(audio.MulawDecode(data))
+	return audio.PCM16Bytes(samples)
+}
+
+// toTwilio converts session audio to μ-law 8 kHz, returning any trailing
+// partial sample to carry into the next chunk.
+func (pl *agentPipeline) toTwilio(data []byte) (frame, carry []byte) {
+	if pl.config.Encoding == twilio.AudioEncodingMulaw {
+		return data, nil
+	}
+	if len(data)%2 == 1 {
+		carry = []byte{data[len(data)-1]}
+		data = data[:len(data)-1]
+	}
+	samples := pl.outbound.Process(audio.PCM16Samples(data))
+	return audio.MulawEncode(samples), carry
+}
+
+// startPipelineLocked starts the agent pipeline once both an agent and a
+// transport are present, traced as a child of the stream's span. It must
+// be called with c.mu held.
+func (c *Call) startPipelineLocked() {
+	if c.agent == nil || c.transport == nil || c.pipeline != nil {
+		return
+	}
+	ctx := c.provider.tracer.Extract(context.Background(), c.trace)
+	if tc, ok := c.transport.(*transport.Connection); ok {
+		ctx = tc.TraceContext(ctx)
+	}
+	c.pipeline = newAgentPipeline(ctx, c, c.agent, c.transport, c.provider.agentPipeline)
+}
+
+// stopPipelineLocked stops the agent pipeline, if running. It must be
+// called with c.mu held.
+func (c *Call) stopPipelineLocked() {
+	if c.pipeline != nil {
+		c.pipeline.close()
+		c.pipeline = nil
+	}
+}
+
+// teardownAgent stops the pipeline and the agent session when the call ends.
+func (c *Call) teardownAgent(ctx context.Context) {
+	c.mu.Lock()
+	session := c.agent
+	c.agent = nil
+	c.stopPipelineLocked()
+	c.mu.Unlock()
+
+	if session != nil {
+		_ = session.Stop(ctx)
+		c.provider.publish(EventAgentDetached, c, session)
+	}
+}
@@ -0,0 +1,310 @@
+package callsystem
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/internal/client"
+)
+
+// AnsweredBy is the answering machine detection result reported by Twilio.
+type AnsweredBy string
+
+// Answering machine detection results.
+const (
+	AnsweredByHuman             AnsweredBy = "human"
+	AnsweredByMachineStart      AnsweredBy = "machine_start"
+	AnsweredByMachineEndBeep    AnsweredBy = "machine_end_beep"
+	AnsweredByMachineEndSilence AnsweredBy = "machine_end_silence"
+	AnsweredByMachineEndOther   AnsweredBy = "machine_end_other"
+	AnsweredByFax               AnsweredBy = "fax"
+	AnsweredByUnknown           AnsweredBy = "unknown"
+)
+
+// IsMachine reports whether the call was answered by a machine.
+func (a AnsweredBy) IsMachine() bool {
+	return strings.HasPrefix(string(a), "machine_")
+}
+
+// IsMessageEnd reports whether the machine greeting has finished
+// (only reported in DetectMessageEnd mode).
+func (a AnsweredBy) IsMessageEnd() bool {
+	return strings.HasPrefix(string(a), "machine_end_")
+}
+
+// Machine detection modes.
+const (
+	MachineDetectionEnable           = "Enable"
+	MachineDetectionDetectMessageEnd = "DetectMessageEnd"
+)
+
+// ErrAnsweredByHuman is returned by LeaveVoicemail when a person answered.
+var ErrAnsweredByHuman = errors.New("call answered by a human")
+
+// MachineDetectionConfig tunes answering machine detection for calls made
+// with callsystem.WithMachineDetection. Zero values use Twilio's defaults.
+type MachineDetectionConfig struct {
+	// Mode is MachineDetectionEnable (default) or MachineDetectionDetectMessageEnd.
+	// DetectMessageEnd waits for the greeting to end and is required by
+	// LeaveVoicemail.
+	Mode string
+
+	// CallbackURL enables asynchronous detection. Twilio posts the result
+	// here; pass the request to Provider.HandleMachineDetectionCallback.
+	CallbackURL string
+
+	// Timeout is how long to wait for a result.
+	Timeout time.Duration
+
+	// SpeechThreshold is the length of speech that indicates a machine.
+	SpeechThreshold time.Duration
+
+	// SpeechEndThreshold is the silence after speech that ends it.
+	SpeechEndThreshold time.Duration
+
+	// SilenceTimeout is the initial silence before the result is "unknown".
+	SilenceTimeout time.Duration
+}
+
+// apply sets the detection parameters on an outbound call request.
+func (c MachineDetectionConfig) apply(params *client.MakeCallParams) {
+	params.MachineDetection = c.Mode
+	if params.MachineDetection == "" {
+		params.MachineDetection = MachineDetectionEnable
+	}
+	if c.CallbackURL != "" {
+		params.AsyncAmd = true
+		params.AsyncAmdCallback = c.CallbackURL
+	}
+	params.MachineDetectionTimeout = int(c.Timeout / time.Second)
+	params.MachineDetectionSpeechThreshold = int(c.SpeechThreshold / time.Millisecond)
+	params.MachineDetectionSpeechEndThreshold = int(c.SpeechEndThreshold / time.Millisecond)
+	params.MachineDetectionSilenceTimeout = int(c.SilenceTimeout / time.Millisecond)
+}
+
+// MachineDetectionResult is the outcome of answering machine detection.
+type MachineDetectionResult struct {
+	// AnsweredBy is who or what answered the call.
+	AnsweredBy AnsweredBy
+
+	// Duration is how long detection took.
+	Duration time.Duration
+
+	// ReceivedAt is when the result was received.
+	ReceivedAt time.Time
+}
+
+// MachineDetectionHandler is called when a detection result arrives.
+type MachineDetectionHandler func(call *Call, result MachineDetectionResult)
+
+// WithMachineDetectionConfig sets answering machine detection tuning.
+func WithMachineDetectionConfig(config MachineDetectionConfig) Option {
+	return func(o *options) {
+		o.machineDetection = config
+	}
+}
+
+// OnMachineDetection sets the handler for answering machine detection results.
+func (p *Provider) OnMachineDetection(handler MachineDetectionHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.amdHandler = handler
+}
+
+// HandleMachineDetectionCallback processes a Twilio async AMD callback
+// (AsyncAmdStatusCallback). Pass the parsed request form. Results for
+// unknown calls are ignored.
+func (p *Provider) HandleMachineDetectionCallback(form url.Values) {
+	ctx := context.Background()
+	call, err := p.lookupCall(ctx, form.Get("CallSid"))
+	if err != nil {
+		return
+	}
+
+	var duration time.Duration
+	if ms, err := strconv.Atoi(form.Get("MachineDetectionDuration")); err == nil {
+		duration = time.Duration(ms) * time.Millisecond
+	}
+
+	p.applyMachineDetection(call, form.Get("AnsweredBy"), duration)
+}
+
+// applyMachineDetection stores a detection result on the call and
+// notifies the handler the first time a result arrives.
+func (p *Provider) applyMachineDetection(call *Call, answeredBy string, duration time.Duration) {
+	if answeredBy == "" {
+		return
+	}
+
+	result := MachineDetectionResult{
+		AnsweredBy: AnsweredBy(answeredBy),
+		Duration:   duration,
+		ReceivedAt: time.Now(),
+	}
+	if !call.setMachineDetection(result) {
+		return
+	}
+
+	_ = p.store.Update(context.Background(), call.id, func(record *CallRecord) error {
+		record.AnsweredBy = result.AnsweredBy
+		record.UpdatedAt = time.Now()
+		return nil
+	})
+
+	p.mu.RLock()
+	handler := p.amdHandler
+	p.mu.RUnlock()
+
+	if handler != nil {
+		handler(call, result)
+	}
+}
+
+// setMachineDetection stores the result, reporting false if one was
+// already recorded.
+func (c *Call) setMachineDetection(result MachineDetectionResult) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.amd != nil {
+		return false
+	}
+	c.amd = &result
+	close(c.amdReadyLocked())
+	return true
+}
+
+// amdReadyLocked returns the channel closed when a detection result
+// arrives. It must be called with c.mu held.
+func (c *Call) amdReadyLocked() chan struct{} {
+	if c.amdReady == nil {
+		c.amdReady = make(chan struct{})
+	}
+	return c.amdReady
+}
+
+// MachineDetection returns the answering machine detection result, if any.
+func (c *Call) MachineDetection() (MachineDetectionResult, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.amd == nil {
+		return MachineDetectionResult{}, false
+	}
+	return *c.amd, true
+}
+
+// AnsweredBy returns who answered the call, or "" if detection has not
+// completed.
+func (c *Call) AnsweredBy() AnsweredBy {
+	result, _ := c.MachineDetection()
+	return result.AnsweredBy
+}
+
+// WaitForMachineDetection blocks until a detection result arrives or ctx
+// is done.
+func (c *Call) WaitForMachineDetection(ctx context.Context) (MachineDetectionResult, error) {
+	c.mu.Lock()
+	ready := c.amdReadyLocked()
+	c.mu.Unlock()
+
+	select {
+	case <-ready:
+		result, _ := c.MachineDetection()
+		return result, nil
+	case <-ctx.Done():
+		return MachineDetectionResult{}, ctx.Err()
+	}
+}
+
+// VoicemailMessage is the message LeaveVoicemail plays after the beep.
+// Set Text to speak it, or AudioURL to play a recording.
+type VoicemailMessage struct {
+	Text     string
+	Voice    string
+	Language string
+	AudioURL string
+}
+
+// LeaveVoicemail waits for the machine greeting to finish, plays message
+// and hangs up. The call must use DetectMessageEnd mode. It returns
+// ErrAnsweredByHuman if a person answered, leaving the call untouched.
+func (c *Call) LeaveVoicemail(ctx context.Context, message VoicemailMessage) error {
+	if message.Text == "" && message.AudioURL == "" {
+		return fmt.Errorf("voicemail message requires text or an audio URL")
+	}
+
+	result, err := c.WaitForMachineDetection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed waiting for machine detection: %w", err)
+	}
+
+	switch {
+	case result.AnsweredBy == AnsweredByHuman:
+		return ErrAnsweredByHuman
+	case result.AnsweredBy == AnsweredByMachineStart:
+		return fmt.Errorf("machine greeting end not detected; use MachineDetectionDetectMessageEnd")
+	case !result.AnsweredBy.IsMessageEnd():
+		return fmt.Errorf("cannot leave voicemail: answered by %s", result.AnsweredBy)
+	}
+
+	twiml, err := buildVoicemailTwiML(message)
+	if err != nil {
+		return err
+	}
+
+	c.setStopExpected(true)
+	if _, err := c.provider.client.UpdateCall(c.TraceContext(ctx), c.id, &client.UpdateCallParams{Twiml: twiml}); err != nil {
+		c.setStopExpected(false)
+		return fmt.Errorf("failed to leave voicemail: %w", err)
+	}
+
+	// The voicemail TwiML hangs up once the message has played
+	c.mu.Lock()
+	c.expectEndLocked(EndReasonMachineDetected, PartyApplication)
+	pending := c.pendingEnd
+	c.mu.Unlock()
+	if pending != nil {
+		c.provider.persistPendingEnd(c.id, pending)
+	}
+	return nil
+}
+
+// sayElement represents a TwiML <Say> element.
+type sayElement struct {
+	XMLName  xml.Name `xml:"Say"`
+	Voice    string   `xml:"voice,attr,omitempty"`
+	Language string   `xml:"language,attr,omitempty"`
+	Text     string   `xml:",chardata"`
+}
+
+// voicemailResponse represents the TwiML played into a voicemail box.
+type voicemailResponse struct {
+	XMLName xml.Name `xml:"Response"`
+	Say     *sayElement
+	Play    string    `xml:"Play,omitempty"`
+	Hangup  *struct{} `xml:"Hangup"`
+}
+
+// buildVoicemailTwiML creates TwiML that plays message and hangs up.
+func buildVoicemailTwiML(message VoicemailMessage) (string, error) {
+	response := voicemailResponse{
+		Play:   message.AudioURL,
+		Hangup: &struct{}{},
+	}
+	if message.Text != "" {
+		response.Say = &sayElement{Voice: message.Voice, Language: message.Language, Text: message.Text}
+	}
+
+	xmlBytes, err := xml.MarshalIndent(response, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("failed to build voicemail TwiML: %w", err)
+	}
+	return xml.Header + string(xmlBytes), nil
+}
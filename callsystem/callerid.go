@@ -0,0 +1,271 @@
+package callsystem
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/phonenumber"
+)
+
+// CallerIDStrategy chooses between equally suitable caller IDs.
+type CallerIDStrategy string
+
+// Caller ID strategies.
+const (
+	// CallerIDRoundRobin cycles through the pool in order.
+	CallerIDRoundRobin CallerIDStrategy = "round_robin"
+
+	// CallerIDLeastRecentlyUsed picks the number used longest ago.
+	CallerIDLeastRecentlyUsed CallerIDStrategy = "least_recently_used"
+)
+
+// CallerIDPoolConfig configures caller ID selection for outbound calls
+// without an explicit From.
+type CallerIDPoolConfig struct {
+	// Numbers is the pool of caller IDs in E.164 format. If empty, the
+	// account's voice-capable numbers are loaded with ListPhoneNumbers on
+	// first use.
+	Numbers []string
+
+	// Strategy chooses between candidates. Defaults to CallerIDRoundRobin.
+	Strategy CallerIDStrategy
+
+	// LocalPresence prefers numbers that share the destination's country
+	// and area code, i.e. the longest common leading digits.
+	LocalPresence bool
+
+	// Excluded lists numbers never to use, e.g. ones flagged as spam.
+	// Numbers can also be flagged at runtime with CallerIDPool.Flag.
+	Excluded []string
+}
+
+// WithCallerIDPool selects caller IDs for outbound calls from a pool.
+// An explicit From on MakeCall still takes precedence.
+func WithCallerIDPool(config CallerIDPoolConfig) Option {
+	return func(o *options) {
+		o.callerIDs = &config
+	}
+}
+
+// CallerIDPool selects caller IDs for outbound calls.
+type CallerIDPool struct {
+	strategy      CallerIDStrategy
+	localPresence bool
+	region        string // for numbers passed to Flag and Unflag
+	load          func(ctx context.Context) ([]string, error)
+
+	mu       sync.Mutex
+	numbers  []string
+	loaded   bool
+	flagged  map[string]bool
+	lastUsed map[string]time.Time
+	next     int
+}
+
+// newCallerIDPool creates a pool from a config with normalized numbers.
+// If the config lists no numbers they are fetched with load on first use.
+func newCallerIDPool(config CallerIDPoolConfig, region string, load func(ctx context.Context) ([]string, error)) *CallerIDPool {
+	pool := &CallerIDPool{
+		strategy:      config.Strategy,
+		localPresence: config.LocalPresence,
+		region:        region,
+		load:          load,
+		numbers:       append([]string(nil), config.Numbers...),
+		loaded:        len(config.Numbers) > 0,
+		flagged:       make(map[string]bool),
+		lastUsed:      make(map[string]time.Time),
+	}
+	if pool.strategy == "" {
+		pool.strategy = CallerIDRoundRobin
+	}
+	for _, number := range config.Excluded {
+		pool.flagged[number] = true
+	}
+	return pool
+}
+
+// Select returns the caller ID to use for a call to to.
+func (p *CallerIDPool) Select(ctx context.Context, to string) (string, error) {
+	if err := p.ensureLoaded(ctx); err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	candidates := make([]int, 0, len(p.numbers))
+	for i, number := range p.numbers {
+		if !p.flagged[number] {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no caller IDs available")
+	}
+
+	if p.localPresence {
+		candidates = p.mostLocal(candidates, to)
+	}
+
+	var chosen int
+	switch p.strategy {
+	case CallerIDLeastRecentlyUsed:
+		chosen = candidates[0]
+		for _, i := range candidates[1:] {
+			if p.lastUsed[p.numbers[i]].Before(p.lastUsed[p.numbers[chosen]]) {
+				chosen = i
+			}
+		}
+	default:
+		// The first candidate at or after the cursor, wrapping around
+		chosen = candidates[0]
+		for _, i := range candidates {
+			if i >= p.next {
+				chosen = i
+				break
+			}
+		}
+		p.next = chosen + 1
+	}
+
+	number := p.numbers[chosen]
+	p.lastUsed[number] = time.Now()
+	return number, nil
+}
+
+// Flag excludes a number from selection, e.g. after it is reported as
+// spam.
+func (p *CallerIDPool) Flag(number string) {
+	number = p.normalize(number)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flagged[number] = true
+}
+
+// Unflag makes a flagged number available again.
+func (p *CallerIDPool) Unflag(number string) {
+	number = p.normalize(number)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.flagged, number)
+}
+
+// normalize returns number in E.164 format, or unchanged if it cannot be
+// parsed.
+func (p *CallerIDPool) normalize(number string) string {
+	if normalized, err := phonenumber.Normalize(number, p.region); err == nil {
+		return normalized
+	}
+	return number
+}
+
+// Numbers returns the numbers in the pool that are not flagged.
+func (p *CallerIDPool) Numbers() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	numbers := make([]string, 0, len(p.numbers))
+	for _, number := range p.numbers {
+		if !p.flagged[number] {
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers
+}
+
+// Refresh reloads the pool from the account's phone numbers. It is a no-op
+// for pools configured with explicit numbers.
+func (p *CallerIDPool) Refresh(ctx context.Context) error {
+	if p.load == nil {
+		return nil
+	}
+
+	numbers, err := p.load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load caller IDs: %w", err)
+	}
+
+	p.mu.Lock()
+	p.numbers = numbers
+	p.loaded = true
+	p.next = 0
+	p.mu.Unlock()
+	return nil
+}
+
+// ensureLoaded loads the pool on first use.
+func (p *CallerIDPool) ensureLoaded(ctx context.Context) error {
+	p.mu.Lock()
+	loaded := p.loaded
+	p.mu.Unlock()
+
+	if loaded {
+		return nil
+	}
+	return p.Refresh(ctx)
+}
+
+// mostLocal narrows candidates to those sharing the most leading digits
+// with to. It must be called with p.mu held.
+func (p *CallerIDPool) mostLocal(candidates []int, to string) []int {
+	best := 0
+	var local []int
+	for _, i := range candidates {
+		n := commonPrefix(digits(p.numbers[i]), digits(to))
+		switch {
+		case n > best:
+			best = n
+			local = []int{i}
+		case n == best:
+			local = append(local, i)
+		}
+	}
+	return local
+}
+
+// digits returns the digits of a phone number.
+func digits(number string) string {
+	var b strings.Builder
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// commonPrefix returns the length of the common prefix of a and b.
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// CallerIDPool returns the provider's caller ID pool, or nil if none is
+// configured.
+func (p *Provider) CallerIDPool() *CallerIDPool {
+	return p.callerIDs
+}
+
+// loadCallerIDs returns the account's voice-capable numbers.
+func (p *Provider) loadCallerIDs(ctx context.Context) ([]string, error) {
+	phoneNumbers, err := p.client.ListPhoneNumbers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]string, 0, len(phoneNumbers))
+	for _, number := range phoneNumbers {
+		if number.Capabilities.Voice {
+			numbers = append(numbers, number.PhoneNumber)
+		}
+	}
+	return numbers, nil
+}
@@ -0,0 +1,314 @@
+package callsystem
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/logging"
+)
+
+// EventType identifies the type of call event.
+type EventType string
+
+// Call lifecycle events.
+const (
+	// EventCallCreated indicates a call was created locally, by MakeCall
+	// or an incoming call webhook.
+	EventCallCreated EventType = "call.created"
+
+	// EventCallInitiated indicates Twilio queued or started dialing the call.
+	EventCallInitiated EventType = "call.initiated"
+
+	// EventCallRinging indicates the call is ringing.
+	EventCallRinging EventType = "call.ringing"
+
+	// EventCallAnswered indicates the call was answered.
+	EventCallAnswered EventType = "call.answered"
+
+	// EventCallCompleted indicates a connected call ended normally.
+	EventCallCompleted EventType = "call.completed"
+
+	// EventCallFailed indicates the call ended without connecting
+	// (busy, no-answer, failed or canceled).
+	EventCallFailed EventType = "call.failed"
+
+	// EventCallEnded indicates the call ended for any reason. It is
+	// published exactly once per call; Data is the call's *EndInfo.
+	EventCallEnded EventType = "call.ended"
+)
+
+// Media and agent events.
+const (
+	// EventStreamAttached indicates a Media Stream connection was bound
+	// to the call. Data is the transport connection.
+	EventStreamAttached EventType = "stream.attached"
+
+	// EventStreamDetached indicates the call's Media Stream disconnected.
+	EventStreamDetached EventType = "stream.detached"
+
+	// EventDTMF indicates a DTMF digit was received. Data is the digit.
+	EventDTMF EventType = "dtmf.digit"
+
+	// EventDTMFSequence indicates a collected DTMF sequence completed.
+	// Data is a *transport.DTMFSequence.
+	EventDTMFSequence EventType = "dtmf.sequence"
+
+	// EventAgentAttached indicates an agent session was attached.
+	// Data is the agent.Session.
+	EventAgentAttached EventType = "agent.attached"
+
+	// EventAgentDetached indicates an agent session was detached.
+	// Data is the agent.Session.
+	EventAgentDetached EventType = "agent.detached"
+
+	// EventAgentEvent relays an event from the attached agent session,
+	// whose Events channel is consumed by the audio pipeline.
+	// Data is the agent.Event.
+	EventAgentEvent EventType = "agent.event"
+)
+
+// DefaultSubscriberBuffer is the default channel capacity for each
+// subscriber.
+const DefaultSubscriberBuffer = 100
+
+// Event is a call event delivered to subscribers.
+type Event struct {
+	// Type is the event type.
+	Type EventType
+
+	// CallID is the call SID.
+	CallID string
+
+	// Call is the call the event belongs to, if it is known locally.
+	Call *Call
+
+	// Time is when the event occurred.
+	Time time.Time
+
+	// Status is the status callback that produced the event, if any.
+	Status *StatusCallback
+
+	// Data contains event-specific data (see the EventType docs).
+	Data any
+}
+
+// SubscribeOption configures a subscription.
+type SubscribeOption func(*subscriber)
+
+// WithCallFilter only delivers events for the given call SIDs.
+func WithCallFilter(callIDs ...string) SubscribeOption {
+	return func(s *subscriber) {
+		if s.calls == nil {
+			s.calls = make(map[string]bool, len(callIDs))
+		}
+		for _, id := range callIDs {
+			s.calls[id] = true
+		}
+	}
+}
+
+// WithEventTypes only delivers events of the given types.
+func WithEventTypes(types ...EventType) SubscribeOption {
+	return func(s *subscriber) {
+		if s.types == nil {
+			s.types = make(map[EventType]bool, len(types))
+		}
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+}
+
+// WithBufferSize sets the subscriber's channel capacity.
+func WithBufferSize(size int) SubscribeOption {
+	return func(s *subscriber) {
+		s.buffer = size
+	}
+}
+
+// Subscription is a registered event subscriber.
+type Subscription struct {
+	sub *subscriber
+	bus *eventBus
+}
+
+// Events returns the channel events are delivered on. It is closed when
+// the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.sub.ch
+}
+
+// Dropped returns how many events were dropped because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.sub.dropped.Load()
+}
+
+// Unsubscribe ends the subscription and closes its channel.
+func (s *Subscription) Unsubscribe() {
+	s.bus.unsubscribe(s.sub.id)
+}
+
+// subscriber is a single event bus consumer.
+type subscriber struct {
+	id      int
+	ch      chan Event
+	buffer  int
+	calls   map[string]bool
+	types   map[EventType]bool
+	dropped atomic.Uint64
+}
+
+// matches reports whether the subscriber wants event.
+func (s *subscriber) matches(event Event) bool {
+	if s.calls != nil && !s.calls[event.CallID] {
+		return false
+	}
+	if s.types != nil && !s.types[event.Type] {
+		return false
+	}
+	return true
+}
+
+// eventBus fans events out to subscribers without blocking the publisher.
+type eventBus struct {
+	mu      sync.RWMutex
+	nextID  int
+	subs    map[int]*subscriber
+	logger  *slog.Logger
+	metrics callMetrics
+}
+
+func newEventBus(logger *slog.Logger, metrics callMetrics) *eventBus {
+	return &eventBus{subs: make(map[int]*subscriber), logger: logger, metrics: metrics}
+}
+
+// subscribe registers a subscriber.
+func (b *eventBus) subscribe(opts ...SubscribeOption) *Subscription {
+	sub := &subscriber{buffer: DefaultSubscriberBuffer}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	if sub.buffer < 1 {
+		sub.buffer = 1
+	}
+	sub.ch = make(chan Event, sub.buffer)
+
+	b.mu.Lock()
+	sub.id = b.nextID
+	b.nextID++
+	b.subs[sub.id] = sub
+	b.mu.Unlock()
+
+	return &Subscription{sub: sub, bus: b}
+}
+
+// unsubscribe removes a subscriber and closes its channel.
+func (b *eventBus) unsubscribe(id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if sub, ok := b.subs[id]; ok {
+		delete(b.subs, id)
+		close(sub.ch)
+	}
+}
+
+// publish delivers event to every matching subscriber, dropping it for
+// any whose buffer is full.
+func (b *eventBus) publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.log(event)
+	b.measure(event)
+	b.trace(event)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// log logs call lifecycle, stream and agent events. DTMF digits and agent
+// output are not logged.
+func (b *eventBus) log(event Event) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{slog.String(logging.KeyCallSID, event.CallID)}
+
+	switch event.Type {
+	case EventCallCreated:
+		level = slog.LevelInfo
+		if call := event.Call; call != nil {
+			attrs = append(attrs,
+				slog.String(logging.KeyDirection, string(call.Direction())),
+				slog.String(logging.KeyFrom, call.From()),
+				slog.String(logging.KeyTo, call.To()),
+			)
+		}
+	case EventCallAnswered, EventCallCompleted, EventCallFailed:
+		level = slog.LevelInfo
+	case EventCallEnded:
+		level = slog.LevelInfo
+		if info, ok := event.Data.(*EndInfo); ok && info != nil {
+			attrs = append(attrs,
+				slog.String(logging.KeyReason, string(info.Reason)),
+				slog.String("hung_up_by", string(info.HungUpBy)),
+			)
+			if info.Status != "" {
+				attrs = append(attrs, slog.String(logging.KeyStatus, info.Status))
+			}
+			if info.ErrorCode != 0 {
+				attrs = append(attrs, slog.Int("twilio_code", info.ErrorCode))
+			}
+		}
+	case EventCallInitiated, EventCallRinging, EventStreamAttached, EventStreamDetached,
+		EventAgentAttached, EventAgentDetached:
+	default:
+		return
+	}
+
+	if event.Status != nil && event.Status.CallStatus != "" && event.Type != EventCallEnded {
+		attrs = append(attrs, slog.String(logging.KeyStatus, event.Status.CallStatus))
+	}
+	b.logger.LogAttrs(context.Background(), level, string(event.Type), attrs...)
+}
+
+// close unsubscribes everyone.
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, sub := range b.subs {
+		delete(b.subs, id)
+		close(sub.ch)
+	}
+}
+
+// Subscribe registers a call event subscriber. Delivery never blocks the
+// provider: events are dropped for subscribers whose buffer is full.
+// Call Unsubscribe when done.
+func (p *Provider) Subscribe(opts ...SubscribeOption) *Subscription {
+	return p.events.subscribe(opts...)
+}
+
+// publish sends an event for call to subscribers.
+func (p *Provider) publish(eventType EventType, call *Call, data any) {
+	p.events.publish(Event{
+		Type:   eventType,
+		CallID: call.id,
+		Call:   call,
+		Data:   data,
+	})
+}
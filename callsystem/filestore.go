@@ -0,0 +1,226 @@
+package callsystem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/agentplexus/omnivoice/callsystem"
+)
+
+const (
+	// fileLockRetry is how often FileStore retries a held lock.
+	fileLockRetry = 10 * time.Millisecond
+
+	// fileLockStale is the age after which an abandoned lock is broken.
+	fileLockStale = 30 * time.Second
+)
+
+// Verify interface compliance at compile time.
+var _ CallStore = (*FileStore)(nil)
+
+// FileStore is a CallStore that keeps one JSON file per call in a
+// directory. Pointing every replica at the same shared directory (e.g. a
+// network volume) shares call state between them. Updates are serialized
+// per call with lock files, so they are safe across processes.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a file-backed call store in dir, creating the
+// directory if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create call store directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Get returns the call record, or ErrCallNotFound.
+func (s *FileStore) Get(ctx context.Context, id string) (*CallRecord, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	return readRecord(path)
+}
+
+// Put creates or replaces a call record.
+func (s *FileStore) Put(ctx context.Context, record *CallRecord) error {
+	path, err := s.path(record.ID)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := s.lock(ctx, record.ID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writeRecord(path, record)
+}
+
+// Delete removes a call record.
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := s.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete call: %w", err)
+	}
+	return nil
+}
+
+// List returns all call records.
+func (s *FileStore) List(ctx context.Context) ([]*CallRecord, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*CallRecord, 0, len(matches))
+	for _, path := range matches {
+		record, err := readRecord(path)
+		if errors.Is(err, ErrCallNotFound) {
+			continue // Deleted since the directory was read
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Update atomically applies fn to a call record.
+func (s *FileStore) Update(ctx context.Context, id string, fn func(record *CallRecord) error) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := s.lock(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	record, err := readRecord(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(record); err != nil {
+		return err
+	}
+	record.ID = id
+	return writeRecord(path, record)
+}
+
+// CompareAndSwapStatus sets the call status to new if it is currently old.
+func (s *FileStore) CompareAndSwapStatus(ctx context.Context, id string, old, new callsystem.CallStatus) (bool, error) {
+	errMismatch := errors.New("status mismatch")
+
+	err := s.Update(ctx, id, func(record *CallRecord) error {
+		if record.Status != old {
+			return errMismatch
+		}
+		record.Status = new
+		record.UpdatedAt = time.Now()
+		return nil
+	})
+	if errors.Is(err, errMismatch) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// path returns the record file for a call, rejecting IDs that are not
+// safe file names.
+func (s *FileStore) path(id string) (string, error) {
+	if id == "" || strings.IndexFunc(id, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+	}) >= 0 {
+		return "", fmt.Errorf("invalid call ID %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// lock acquires the per-call lock file, breaking locks older than
+// fileLockStale left behind by crashed processes.
+func (s *FileStore) lock(ctx context.Context, id string) (func(), error) {
+	lockPath := filepath.Join(s.dir, id+".lock")
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock call: %w", err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > fileLockStale {
+			_ = os.Remove(lockPath)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to lock call: %w", ctx.Err())
+		case <-time.After(fileLockRetry):
+		}
+	}
+}
+
+// readRecord reads a call record file.
+func readRecord(path string) (*CallRecord, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCallNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read call: %w", err)
+	}
+
+	var record CallRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse call record: %w", err)
+	}
+	return &record, nil
+}
+
+// writeRecord atomically replaces a call record file.
+func writeRecord(path string, record *CallRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode call record: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write call: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write call: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package callsystem
+
+import (
+	"context"
+	"time"
+
+	"github.com/agentplexus/omnivoice/callsystem"
+)
+
+// EndReason describes why a call ended.
+type EndReason string
+
+// End reasons.
+const (
+	// EndReasonRemoteHangup means the party on the phone hung up: the
+	// caller on inbound calls, the callee on outbound calls.
+	EndReasonRemoteHangup EndReason = "remote_hangup"
+
+	// EndReasonAPIHangup means the application hung up with Hangup.
+	EndReasonAPIHangup EndReason = "api_hangup"
+
+	// EndReasonProviderClosed means the call was hung up by Provider.Close.
+	EndReasonProviderClosed EndReason = "provider_closed"
+
+	// EndReasonNoAnswer means an outbound call was not answered, or a
+	// deferred inbound call was not answered before its AnswerTimeout.
+	EndReasonNoAnswer EndReason = "no_answer"
+
+	// EndReasonBusy means the callee was busy.
+	EndReasonBusy EndReason = "busy"
+
+	// EndReasonRejected means the application rejected an inbound call.
+	EndReasonRejected EndReason = "rejected"
+
+	// EndReasonCanceled means the call was canceled before it connected.
+	EndReasonCanceled EndReason = "canceled"
+
+	// EndReasonFailed means Twilio could not connect the call; see the
+	// SIP response and error codes in EndInfo.
+	EndReasonFailed EndReason = "failed"
+
+	// EndReasonMachineDetected means the call was ended after it was
+	// answered by a machine (including after leaving a voicemail).
+	EndReasonMachineDetected EndReason = "machine_detected"
+
+	// EndReasonMaxDuration means the call reached CallPolicy.MaxDuration.
+	EndReasonMaxDuration EndReason = "max_duration"
+
+	// EndReasonSilenceTimeout means the caller was silent for longer
+	// than CallPolicy.MaxSilence.
+	EndReasonSilenceTimeout EndReason = "silence_timeout"
+
+	// EndReasonAgentIdle means the agent produced no audio for longer
+	// than CallPolicy.MaxAgentIdle.
+	EndReasonAgentIdle EndReason = "agent_idle"
+
+	// EndReasonStreamDropped means the Media Stream WebSocket dropped,
+	// ending the call.
+	EndReasonStreamDropped EndReason = "stream_dropped"
+
+	// EndReasonUnknown means the reason could not be determined.
+	EndReasonUnknown EndReason = "unknown"
+)
+
+// Party identifies who ended a call.
+type Party string
+
+// Parties.
+const (
+	PartyCaller      Party = "caller"
+	PartyCallee      Party = "callee"
+	PartyApplication Party = "application"
+	PartyNetwork     Party = "network"
+)
+
+// EndInfo describes how a call ended. It is the Data of EventCallEnded.
+type EndInfo struct {
+	// Reason is why the call ended.
+	Reason EndReason `json:"reason"`
+
+	// HungUpBy is who ended the call, if known.
+	HungUpBy Party `json:"hung_up_by,omitempty"`
+
+	// Status is the final Twilio status (e.g. "completed"), once reported
+	// by a status callback.
+	Status string `json:"status,omitempty"`
+
+	// SipResponseCode, ErrorCode and ErrorMessage are copied from the
+	// final status callback, if present.
+	SipResponseCode int    `json:"sip_response_code,omitempty"`
+	ErrorCode       int    `json:"error_code,omitempty"`
+	ErrorMessage    string `json:"error_message,omitempty"`
+}
+
+// EndReason returns why the call ended, or "" while it is active.
+func (c *Call) EndReason() EndReason {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.endInfo == nil {
+		return ""
+	}
+	return c.endInfo.Reason
+}
+
+// EndInfo returns how the call ended, or nil while it is active.
+func (c *Call) EndInfo() *EndInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copyEndInfo(c.endInfo)
+}
+
+// copyEndInfo returns a copy of info, or nil.
+func copyEndInfo(info *EndInfo) *EndInfo {
+	if info == nil {
+		return nil
+	}
+	c := *info
+	return &c
+}
+
+// remoteParty returns the party on the phone.
+func (c *Call) remoteParty() Party {
+	if c.direction == callsystem.Inbound {
+		return PartyCaller
+	}
+	return PartyCallee
+}
+
+// endLocked records why the call ended, keeping the first reason. A reason
+// recorded with expectEndLocked takes precedence. It must be called with
+// c.mu held.
+func (c *Call) endLocked(reason EndReason, by Party) {
+	if c.endInfo != nil {
+		return
+	}
+	if c.pendingEnd != nil {
+		c.endInfo = copyEndInfo(c.pendingEnd)
+		return
+	}
+	c.endInfo = &EndInfo{Reason: reason, HungUpBy: by}
+}
+
+// expectEndLocked records the reason for an end the application has
+// caused but Twilio has not reported yet (e.g. a dropped stream). It must
+// be called with c.mu held.
+func (c *Call) expectEndLocked(reason EndReason, by Party) {
+	if c.ended || c.pendingEnd != nil {
+		return
+	}
+	c.pendingEnd = &EndInfo{Reason: reason, HungUpBy: by}
+}
+
+// endFromStatusLocked records the end reason from a terminal status
+// callback, and its final status and error details. It must be called
+// with c.mu held.
+func (c *Call) endFromStatusLocked(cb *StatusCallback) {
+	switch cb.CallStatus {
+	case "completed":
+		// Twilio ends calls that reach the policy's TimeLimit itself
+		policy := c.policy
+		if !c.policySet {
+			policy = c.provider.policy
+		}
+		if limit := policy.timeLimit(); limit > 0 && cb.CallDuration >= time.Duration(limit)*time.Second {
+			c.endLocked(EndReasonMaxDuration, PartyNetwork)
+		}
+		c.endLocked(EndReasonRemoteHangup, c.remoteParty())
+	case "busy":
+		c.endLocked(EndReasonBusy, c.remoteParty())
+	case "no-answer":
+		c.endLocked(EndReasonNoAnswer, c.remoteParty())
+	case "canceled":
+		c.endLocked(EndReasonCanceled, PartyApplication)
+	case "failed":
+		c.endLocked(EndReasonFailed, PartyNetwork)
+	default:
+		c.endLocked(EndReasonUnknown, "")
+	}
+
+	c.endInfo.Status = cb.CallStatus
+	c.endInfo.SipResponseCode = cb.SipResponseCode
+	c.endInfo.ErrorCode = cb.ErrorCode
+	c.endInfo.ErrorMessage = cb.ErrorMessage
+}
+
+// persistPendingEnd saves an expected end reason so that whichever
+// instance receives the final status callback can attribute it.
+func (p *Provider) persistPendingEnd(callID string, pending *EndInfo) {
+	info := copyEndInfo(pending)
+	_ = p.store.Update(context.Background(), callID, func(record *CallRecord) error {
+		if record.Ended || record.PendingEnd != nil {
+			return nil
+		}
+		record.PendingEnd = info
+		record.UpdatedAt = time.Now()
+		return nil
+	})
+}
@@ -0,0 +1,278 @@
+package callsystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/internal/client"
+	"github.com/agentplexus/omnivoice/callsystem"
+)
+
+// DefaultAnswerTimeout is how long a deferred inbound call waits for the
+// application to answer it. Without a hold URL the webhook response is
+// delayed for this long, so it must stay below Twilio's 15 second webhook
+// timeout.
+const DefaultAnswerTimeout = 10 * time.Second
+
+// RejectReason is the reason given when rejecting an inbound call.
+type RejectReason string
+
+// Reject reasons understood by TwiML <Reject>.
+const (
+	// RejectBusy plays a busy signal to the caller.
+	RejectBusy RejectReason = "busy"
+
+	// RejectRejected plays a "not in service" tone to the caller.
+	RejectRejected RejectReason = "rejected"
+)
+
+// InboundConfig controls how incoming calls are answered.
+type InboundConfig struct {
+	// Defer makes incoming calls wait for the application to call Answer,
+	// Reject or Redirect instead of connecting the Media Stream right away.
+	Defer bool
+
+	// AnswerTimeout is how long to wait for a decision before rejecting
+	// the call with TimeoutReason. Defaults to DefaultAnswerTimeout.
+	AnswerTimeout time.Duration
+
+	// TimeoutReason is the reject reason used when AnswerTimeout expires.
+	// Defaults to RejectRejected.
+	TimeoutReason RejectReason
+
+	// HoldURL, if set, is audio (e.g. ringback) played in a loop while the
+	// decision is pending. The webhook then returns immediately and the
+	// decision is applied through the REST API; since playing audio answers
+	// the call, Reject hangs up instead of using <Reject>. Without HoldURL
+	// the webhook response is delayed until the decision, so the caller
+	// hears ringing and Reject uses <Reject>.
+	HoldURL string
+}
+
+// WithInboundConfig sets how incoming calls are answered.
+func WithInboundConfig(config InboundConfig) Option {
+	return func(o *options) {
+		o.inbound = config
+	}
+}
+
+// withDefaults fills in unset fields.
+func (c InboundConfig) withDefaults() InboundConfig {
+	if c.AnswerTimeout <= 0 {
+		c.AnswerTimeout = DefaultAnswerTimeout
+	}
+	if c.TimeoutReason == "" {
+		c.TimeoutReason = RejectRejected
+	}
+	return c
+}
+
+// decisionKind identifies an application's decision on an inbound call.
+type decisionKind int
+
+const (
+	decisionAnswer decisionKind = iota
+	decisionReject
+	decisionRedirect
+)
+
+// answerDecision is how a pending inbound call should proceed.
+type answerDecision struct {
+	kind     decisionKind
+	reason   RejectReason // for decisionReject
+	timedOut bool         // for decisionReject by AnswerTimeout
+	url      string       // for decisionRedirect
+}
+
+// Reject declines a pending inbound call. The call must have been deferred
+// (see InboundConfig).
+func (c *Call) Reject(ctx context.Context, reason RejectReason) error {
+	if reason == "" {
+		reason = RejectRejected
+	}
+	pending, err := c.decide(ctx, answerDecision{kind: decisionReject, reason: reason})
+	if err != nil {
+		return err
+	}
+	if !pending {
+		return fmt.Errorf("call %s is not awaiting an answer", c.id)
+	}
+	return nil
+}
+
+// Redirect hands the call to the TwiML at url. A pending inbound call is
+// redirected instead of being answered; any other call is updated in place.
+func (c *Call) Redirect(ctx context.Context, url string) error {
+	pending, err := c.decide(ctx, answerDecision{kind: decisionRedirect, url: url})
+	if err != nil || pending {
+		return err
+	}
+
+	c.setStopExpected(true)
+	if _, err := c.provider.client.UpdateCall(c.TraceContext(ctx), c.id, &client.UpdateCallParams{URL: url}); err != nil {
+		c.setStopExpected(false)
+		return fmt.Errorf("failed to redirect: %w", err)
+	}
+	return nil
+}
+
+// decide delivers a decision for a pending inbound call, reporting false if
+// no decision is pending. For held calls the decision is applied through
+// the REST API; otherwise it is handed to the waiting webhook.
+func (c *Call) decide(ctx context.Context, d answerDecision) (bool, error) {
+	c.mu.Lock()
+	ch := c.decision
+	c.decision = nil
+	held := c.held
+	if c.answerTimer != nil {
+		c.answerTimer.Stop()
+		c.answerTimer = nil
+	}
+	c.mu.Unlock()
+
+	if ch == nil {
+		return false, nil
+	}
+
+	if held {
+		twiml := c.decisionTwiML(d, true)
+		if _, err := c.provider.client.UpdateCall(c.TraceContext(ctx), c.id, &client.UpdateCallParams{Twiml: twiml}); err != nil {
+			return true, fmt.Errorf("failed to apply answer decision: %w", err)
+		}
+	} else {
+		ch <- d
+	}
+
+	c.provider.applyDecision(ctx, c, d)
+	return true, nil
+}
+
+// awaitDecision blocks the incoming webhook until the application decides
+// or the answer timeout expires, returning the TwiML to respond with.
+func (p *Provider) awaitDecision(call *Call, decision <-chan answerDecision) string {
+	timer := time.NewTimer(p.inbound.AnswerTimeout)
+	defer timer.Stop()
+
+	select {
+	case d := <-decision:
+		return call.decisionTwiML(d, false)
+	case <-timer.C:
+	}
+
+	// Claim the decision; the application may have decided meanwhile
+	d := answerDecision{kind: decisionReject, reason: p.inbound.TimeoutReason, timedOut: true}
+	call.mu.Lock()
+	claimed := call.decision != nil
+	call.decision = nil
+	call.mu.Unlock()
+
+	if !claimed {
+		d = <-decision
+		return call.decisionTwiML(d, false)
+	}
+
+	p.applyDecision(context.Background(), call, d)
+	return call.decisionTwiML(d, false)
+}
+
+// holdCall marks a call as held with HoldURL and starts its answer
+// timeout. It reports false if the call was already decided.
+func (p *Provider) holdCall(call *Call) bool {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+
+	if call.decision == nil {
+		return false
+	}
+	call.held = true
+	call.answerTimer = time.AfterFunc(p.inbound.AnswerTimeout, func() {
+		_, _ = call.decide(context.Background(), answerDecision{
+			kind:     decisionReject,
+			reason:   p.inbound.TimeoutReason,
+			timedOut: true,
+		})
+	})
+	return true
+}
+
+// applyDecision updates call state for a decision.
+func (p *Provider) applyDecision(ctx context.Context, call *Call, d answerDecision) {
+	switch d.kind {
+	case decisionAnswer:
+		_, _ = p.store.CompareAndSwapStatus(ctx, call.id, callsystem.StatusRinging, callsystem.StatusAnswered)
+		call.mu.Lock()
+		call.status = callsystem.StatusAnswered
+		if call.answerTime.IsZero() {
+			call.answerTime = time.Now()
+		}
+		call.mu.Unlock()
+
+	case decisionReject:
+		status := callsystem.StatusFailed
+		if d.reason == RejectBusy {
+			status = callsystem.StatusBusy
+		}
+
+		call.mu.Lock()
+		call.status = status
+		endedNow := !call.ended
+		call.ended = true
+		if call.endTime.IsZero() {
+			call.endTime = time.Now()
+		}
+		if d.timedOut {
+			call.endLocked(EndReasonNoAnswer, PartyApplication)
+		} else {
+			call.endLocked(EndReasonRejected, PartyApplication)
+		}
+		endInfo := copyEndInfo(call.endInfo)
+		call.mu.Unlock()
+
+		p.forget(ctx, call.id)
+		if endedNow {
+			p.publish(EventCallEnded, call, endInfo)
+		}
+	}
+}
+
+// decisionTwiML returns the TwiML that carries out a decision. Calls that
+// were held have already been answered, so they cannot use <Reject>.
+func (c *Call) decisionTwiML(d answerDecision, held bool) string {
+	switch d.kind {
+	case decisionReject:
+		if held {
+			return twimlResponse(`<Hangup/>`)
+		}
+		return twimlResponse(fmt.Sprintf(`<Reject reason="%s"/>`, escapeXML(string(d.reason))))
+	case decisionRedirect:
+		return twimlResponse(fmt.Sprintf(`<Redirect>%s</Redirect>`, escapeXML(d.url)))
+	default:
+		c.mu.RLock()
+		trace := c.trace
+		c.mu.RUnlock()
+		return buildMediaStreamTwiML(c.provider.config.WebhookURL, trace)
+	}
+}
+
+// buildHoldTwiML creates TwiML that plays url in a loop.
+func buildHoldTwiML(url string) string {
+	return twimlResponse(fmt.Sprintf(`<Play loop="0">%s</Play>`, escapeXML(url)))
+}
+
+// twimlResponse wraps verbs in a TwiML document.
+func twimlResponse(verbs string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Response>
+    %s
+</Response>`, verbs)
+}
+
+// escapeXML escapes s for use in XML text or attribute values.
+func escapeXML(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,282 @@
+package callsystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/internal/client"
+	"github.com/agentplexus/omnivoice-twilio/logging"
+	"github.com/agentplexus/omnivoice/callsystem"
+)
+
+// DefaultLookupCacheTTL is how long lookup results are cached unless
+// PreDialCheckConfig.CacheTTL is set.
+const DefaultLookupCacheTTL = 24 * time.Hour
+
+// maxLookupCacheEntries bounds the lookup cache. When it is full, expired
+// entries are swept and then the entry closest to expiry is evicted.
+const maxLookupCacheEntries = 10000
+
+// ErrCallBlocked is matched via errors.Is by MakeCall errors for calls
+// blocked by the pre-dial check.
+var ErrCallBlocked = errors.New("call blocked by pre-dial check")
+
+// LineType is the type of line a number belongs to, as reported by the
+// Twilio Lookup API.
+type LineType string
+
+// Line types.
+const (
+	LineTypeMobile       LineType = "mobile"
+	LineTypeLandline     LineType = "landline"
+	LineTypeFixedVoIP    LineType = "fixedVoip"
+	LineTypeNonFixedVoIP LineType = "nonFixedVoip"
+	LineTypeTollFree     LineType = "tollFree"
+	LineTypePremium      LineType = "premium"
+	LineTypeSharedCost   LineType = "sharedCost"
+	LineTypePersonal     LineType = "personal"
+	LineTypeVoicemail    LineType = "voicemail"
+	LineTypePager        LineType = "pager"
+	LineTypeUAN          LineType = "uan"
+	LineTypeUnknown      LineType = "unknown"
+)
+
+// NumberInfo is what the Lookup API knows about a number.
+type NumberInfo struct {
+	Number           string
+	Valid            bool
+	ValidationErrors []string
+	CountryCode      string // ISO 3166-1 code, e.g. "US"
+	NationalFormat   string
+
+	LineType LineType
+	Carrier  string
+
+	// CallerName and CallerType are set when caller name lookup is
+	// enabled.
+	CallerName string
+	CallerType string
+
+	LookedUpAt time.Time
+}
+
+// PreDialDecision is the outcome of a pre-dial check.
+type PreDialDecision struct {
+	// Block stops the call; MakeCall returns a *BlockedError.
+	Block bool
+
+	// Reason explains a block.
+	Reason string
+
+	// Options are applied to the call after the caller's options, e.g.
+	// to route mobile numbers through a different caller ID or enable
+	// machine detection for landlines.
+	Options []callsystem.CallOption
+}
+
+// PreDialCheckConfig configures number lookups before outbound calls to
+// phone numbers.
+type PreDialCheckConfig struct {
+	// CallerName also requests caller name data (billed separately).
+	CallerName bool
+
+	// AllowInvalid dials numbers Lookup reports as invalid.
+	AllowInvalid bool
+
+	// BlockLineTypes lists line types that are never dialed.
+	BlockLineTypes []LineType
+
+	// Route decides per number, after invalid numbers and BlockLineTypes
+	// have been checked. Optional.
+	Route func(info *NumberInfo) PreDialDecision
+
+	// FailOpen dials anyway if the lookup fails. By default the call
+	// fails with the lookup error.
+	FailOpen bool
+
+	// CacheTTL is how long results are cached per number. Defaults to
+	// DefaultLookupCacheTTL. At most 10,000 numbers are cached.
+	CacheTTL time.Duration
+}
+
+// WithPreDialCheck looks up numbers before dialing them and blocks or
+// routes calls based on the result.
+func WithPreDialCheck(config PreDialCheckConfig) Option {
+	return func(o *options) {
+		o.preDial = &config
+	}
+}
+
+// decide applies the check to a lookup result.
+func (c *PreDialCheckConfig) decide(info *NumberInfo) PreDialDecision {
+	if !info.Valid && !c.AllowInvalid {
+		return PreDialDecision{Block: true, Reason: "invalid number"}
+	}
+	for _, lineType := range c.BlockLineTypes {
+		if info.LineType == lineType {
+			return PreDialDecision{Block: true, Reason: fmt.Sprintf("line type %s is blocked", lineType)}
+		}
+	}
+	if c.Route != nil {
+		return c.Route(info)
+	}
+	return PreDialDecision{}
+}
+
+// BlockedError is returned by MakeCall when the pre-dial check blocks a
+// call. Its message masks the number.
+type BlockedError struct {
+	To     string
+	Reason string
+	Info   *NumberInfo
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("call to %s blocked: %s", logging.MaskPhoneNumber(e.To), e.Reason)
+}
+
+// Unwrap returns ErrCallBlocked.
+func (e *BlockedError) Unwrap() error {
+	return ErrCallBlocked
+}
+
+// lookupEntry is a cached lookup result.
+type lookupEntry struct {
+	info       *NumberInfo
+	callerName bool
+	expires    time.Time
+}
+
+// lookupCache caches lookup results per number.
+type lookupCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]lookupEntry
+}
+
+// newLookupCache creates a cache with the given TTL.
+func newLookupCache(ttl time.Duration) *lookupCache {
+	if ttl <= 0 {
+		ttl = DefaultLookupCacheTTL
+	}
+	return &lookupCache{ttl: ttl, entries: make(map[string]lookupEntry)}
+}
+
+// get returns a cached result that includes caller name data if needed.
+func (c *lookupCache) get(number string, callerName bool) (*NumberInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[number]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, number)
+		return nil, false
+	}
+	if callerName && !entry.callerName {
+		return nil, false
+	}
+	info := *entry.info
+	return &info, true
+}
+
+// put caches a result, making room if the cache is full.
+func (c *lookupCache) put(number string, info *NumberInfo, callerName bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := c.entries[number]; !ok && len(c.entries) >= maxLookupCacheEntries {
+		c.sweepLocked(now)
+	}
+	c.entries[number] = lookupEntry{info: info, callerName: callerName, expires: now.Add(c.ttl)}
+}
+
+// sweepLocked deletes expired entries, then the entry closest to expiry
+// if the cache is still full. It must be called with c.mu held.
+func (c *lookupCache) sweepLocked(now time.Time) {
+	for number, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, number)
+		}
+	}
+	if len(c.entries) < maxLookupCacheEntries {
+		return
+	}
+
+	var oldest string
+	var oldestExpires time.Time
+	for number, entry := range c.entries {
+		if oldest == "" || entry.expires.Before(oldestExpires) {
+			oldest, oldestExpires = number, entry.expires
+		}
+	}
+	delete(c.entries, oldest)
+}
+
+// LookupNumber returns line type, carrier and validity information for an
+// E.164 number, using cached results when available. Caller name data is
+// included if the pre-dial check requests it.
+func (p *Provider) LookupNumber(ctx context.Context, number string) (*NumberInfo, error) {
+	callerName := p.preDial != nil && p.preDial.CallerName
+	if info, ok := p.lookups.get(number, callerName); ok {
+		return info, nil
+	}
+
+	fields := []string{client.LookupLineTypeIntelligence}
+	if callerName {
+		fields = append(fields, client.LookupCallerName)
+	}
+
+	lookup, err := p.client.LookupPhoneNumber(ctx, number, fields...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up number: %w", err)
+	}
+
+	info := &NumberInfo{
+		Number:           lookup.PhoneNumber,
+		Valid:            lookup.Valid,
+		ValidationErrors: lookup.ValidationErrors,
+		CountryCode:      lookup.CountryCode,
+		NationalFormat:   lookup.NationalFormat,
+		LineType:         LineTypeUnknown,
+		LookedUpAt:       time.Now(),
+	}
+	if info.Number == "" {
+		info.Number = number
+	}
+	if lti := lookup.LineTypeIntelligence; lti != nil {
+		if lti.Type != "" {
+			info.LineType = LineType(lti.Type)
+		}
+		info.Carrier = lti.CarrierName
+	}
+	if cn := lookup.CallerName; cn != nil {
+		info.CallerName = cn.CallerName
+		info.CallerType = cn.CallerType
+	}
+
+	cached := *info
+	p.lookups.put(number, &cached, callerName)
+	return info, nil
+}
+
+// preDialCheck runs the pre-dial check for a call to an E.164 number.
+func (p *Provider) preDialCheck(ctx context.Context, to string) (PreDialDecision, error) {
+	info, err := p.LookupNumber(ctx, to)
+	if err != nil {
+		if p.preDial.FailOpen {
+			return PreDialDecision{}, nil
+		}
+		return PreDialDecision{}, err
+	}
+
+	decision := p.preDial.decide(info)
+	if decision.Block {
+		return decision, &BlockedError{To: to, Reason: decision.Reason, Info: info}
+	}
+	return decision, nil
+}
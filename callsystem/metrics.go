@@ -0,0 +1,66 @@
+package callsystem
+
+import (
+	"github.com/agentplexus/omnivoice-twilio/metrics"
+)
+
+// WithMeter reports call counts and REST and Media Streams metrics to
+// meter. See package metrics for the metric names.
+func WithMeter(meter metrics.Meter) Option {
+	return func(o *options) {
+		o.meter = meter
+	}
+}
+
+// callMetrics are the call instruments.
+type callMetrics struct {
+	started metrics.Counter
+	ended   metrics.Counter
+	active  metrics.Gauge
+}
+
+// newCallMetrics creates the call instruments.
+func newCallMetrics(meter metrics.Meter) callMetrics {
+	return callMetrics{
+		started: meter.Counter(metrics.CallsStarted, "Calls created by this instance.", metrics.LabelDirection),
+		ended:   meter.Counter(metrics.CallsEnded, "Calls ended.", metrics.LabelDirection, metrics.LabelReason),
+		active:  meter.Gauge(metrics.CallsActive, "Calls in progress on this instance.", metrics.LabelDirection),
+	}
+}
+
+// measure updates call metrics for an event. A call is active on the
+// instance that created it until it ends there; calls rebuilt from a
+// shared store never counted as active on this instance, so their end
+// does not lower the gauge.
+func (b *eventBus) measure(event Event) {
+	call := event.Call
+	if call == nil {
+		return
+	}
+	direction := string(call.Direction())
+
+	switch event.Type {
+	case EventCallCreated:
+		b.metrics.started.Add(1, direction)
+		call.mu.Lock()
+		counted := !call.gauged
+		call.gauged = true
+		call.mu.Unlock()
+		if counted {
+			b.metrics.active.Add(1, direction)
+		}
+	case EventCallEnded:
+		reason := EndReasonUnknown
+		if info, ok := event.Data.(*EndInfo); ok && info != nil {
+			reason = info.Reason
+		}
+		b.metrics.ended.Add(1, direction, string(reason))
+		call.mu.Lock()
+		counted := call.gauged
+		call.gauged = false
+		call.mu.Unlock()
+		if counted {
+			b.metrics.active.Add(-1, direction)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package callsystem
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/internal/audio"
+	"github.com/agentplexus/omnivoice-twilio/internal/client"
+	"github.com/agentplexus/omnivoice-twilio/logging"
+	"github.com/agentplexus/omnivoice/callsystem"
+)
+
+// Policy defaults.
+const (
+	// DefaultSilenceThreshold is the RMS level (of 16-bit PCM) below which
+	// caller audio counts as silence.
+	DefaultSilenceThreshold = 500
+
+	// DefaultGoodbyeTimeout is how long a goodbye prompt may play before
+	// the call is hung up regardless.
+	DefaultGoodbyeTimeout = 30 * time.Second
+
+	// policyCheckInterval is how often the policy watchdog runs.
+	policyCheckInterval = time.Second
+)
+
+// CallPolicy limits how long a call may run. When a limit is reached the
+// Goodbye message is played and the call is hung up, with EndReason
+// EndReasonMaxDuration, EndReasonSilenceTimeout or EndReasonAgentIdle.
+// Zero limits are disabled.
+type CallPolicy struct {
+	// MaxDuration limits the call duration, measured from answer (or from
+	// start until answered). It is also sent to Twilio as the call's
+	// TimeLimit, plus GoodbyeTimeout, so calls end even if this process
+	// does not: when outbound calls are placed, and when the Media Stream
+	// of inbound calls attaches.
+	MaxDuration time.Duration
+
+	// MaxSilence limits how long the caller may stay silent while a
+	// Media Stream is attached.
+	MaxSilence time.Duration
+
+	// MaxAgentIdle limits how long an attached agent may go without
+	// producing audio while a Media Stream is attached.
+	MaxAgentIdle time.Duration
+
+	// SilenceThreshold is the RMS level below which caller audio counts
+	// as silence. Defaults to DefaultSilenceThreshold.
+	SilenceThreshold int
+
+	// Goodbye is played before hanging up. If empty the call is hung up
+	// immediately.
+	Goodbye VoicemailMessage
+
+	// GoodbyeTimeout bounds how long Goodbye may play. Defaults to
+	// DefaultGoodbyeTimeout.
+	GoodbyeTimeout time.Duration
+}
+
+// WithCallPolicy sets the default policy for calls handled by the
+// provider. Use Call.SetPolicy to override it per call.
+func WithCallPolicy(policy CallPolicy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// withDefaults fills in unset fields.
+func (p CallPolicy) withDefaults() CallPolicy {
+	if p.SilenceThreshold <= 0 {
+		p.SilenceThreshold = DefaultSilenceThreshold
+	}
+	if p.GoodbyeTimeout <= 0 {
+		p.GoodbyeTimeout = DefaultGoodbyeTimeout
+	}
+	return p
+}
+
+// enabled reports whether any limit is set.
+func (p CallPolicy) enabled() bool {
+	return p.MaxDuration > 0 || p.MaxSilence > 0 || p.MaxAgentIdle > 0
+}
+
+// timeLimit returns the Twilio TimeLimit in seconds, or 0 for none.
+func (p CallPolicy) timeLimit() int {
+	if p.MaxDuration <= 0 {
+		return 0
+	}
+	return int(math.Ceil((p.MaxDuration + p.GoodbyeTimeout).Seconds()))
+}
+
+// Policy returns the call's policy.
+func (c *Call) Policy() CallPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.policySet {
+		return c.provider.policy
+	}
+	return c.policy
+}
+
+// SetPolicy replaces the call's policy. If the call is owned by this
+// provider its Twilio TimeLimit is updated to match MaxDuration.
+func (c *Call) SetPolicy(ctx context.Context, policy CallPolicy) error {
+	policy = policy.withDefaults()
+
+	c.mu.Lock()
+	c.policy = policy
+	c.policySet = true
+	ended := c.ended
+	limit := policy.timeLimit()
+	if !ended && limit > 0 {
+		c.timeLimitSent = true
+	}
+	c.mu.Unlock()
+
+	if ended {
+		return nil
+	}
+
+	if limit > 0 {
+		params := &client.UpdateCallParams{TimeLimit: limit}
+		if _, err := c.provider.client.UpdateCall(c.TraceContext(ctx), c.id, params); err != nil {
+			return fmt.Errorf("failed to update call time limit: %w", err)
+		}
+	}
+
+	c.startWatchdog()
+	return nil
+}
+
+// applyTimeLimit sends the policy's TimeLimit to Twilio for an inbound call
+// that is in progress, unless it has been sent already. Outbound calls get
+// theirs when placed.
+func (c *Call) applyTimeLimit() {
+	c.mu.Lock()
+	policy := c.provider.policy
+	if c.policySet {
+		policy = c.policy
+	}
+	limit := policy.timeLimit()
+	if c.direction != callsystem.Inbound || c.timeLimitSent || c.ended || limit == 0 {
+		c.mu.Unlock()
+		return
+	}
+	c.timeLimitSent = true
+	c.mu.Unlock()
+
+	ctx := c.TraceContext(context.Background())
+	if _, err := c.provider.client.UpdateCall(ctx, c.id, &client.UpdateCallParams{TimeLimit: limit}); err != nil {
+		c.provider.logger.Warn("failed to set call time limit", slog.String(logging.KeyCallSID, c.id), logging.Error(err))
+	}
+}
+
+// startWatchdog enforces the call's policy until it ends. It is a no-op if
+// the policy has no limits or the watchdog is already running.
+func (c *Call) startWatchdog() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.policySet {
+		c.policy = c.provider.policy
+		c.policySet = true
+	}
+	if c.watching || c.ended || !c.policy.enabled() {
+		return
+	}
+	c.watching = true
+
+	go func() {
+		ticker := time.NewTicker(policyCheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if !c.checkPolicy() {
+				return
+			}
+		}
+	}()
+}
+
+// checkPolicy ends the call if a policy limit has been reached. It reports
+// whether the watchdog should keep running.
+func (c *Call) checkPolicy() bool {
+	c.mu.Lock()
+	if c.ended || c.policyEnding {
+		c.watching = false
+		c.mu.Unlock()
+		return false
+	}
+
+	policy := c.policy
+	if !policy.enabled() {
+		c.watching = false
+		c.mu.Unlock()
+		return false
+	}
+
+	now := time.Now()
+
+	start := c.answerTime
+	if start.IsZero() {
+		start = c.startTime
+	}
+
+	// Silence and agent idleness are only measured while a stream is
+	// attached; a transferred call has none
+	streaming := c.transport != nil
+
+	var reason EndReason
+	switch {
+	case policy.MaxDuration > 0 && now.Sub(start) >= policy.MaxDuration:
+		reason = EndReasonMaxDuration
+	case policy.MaxSilence > 0 && streaming && !c.lastVoice.IsZero() &&
+		now.Sub(c.lastVoice) >= policy.MaxSilence:
+		reason = EndReasonSilenceTimeout
+	case policy.MaxAgentIdle > 0 && streaming && c.agent != nil && !c.lastAgentOutput.IsZero() &&
+		now.Sub(c.lastAgentOutput) >= policy.MaxAgentIdle:
+		reason = EndReasonAgentIdle
+	}
+
+	if reason == "" {
+		c.mu.Unlock()
+		return true
+	}
+
+	c.policyEnding = true
+	c.watching = false
+	c.mu.Unlock()
+
+	go c.endByPolicy(context.Background(), policy, reason)
+	return false
+}
+
+// endByPolicy plays the policy's goodbye prompt and hangs up.
+func (c *Call) endByPolicy(ctx context.Context, policy CallPolicy, reason EndReason) {
+	c.provider.logger.Info("ending call by policy", slog.String(logging.KeyCallSID, c.id), slog.String(logging.KeyReason, string(reason)))
+
+	c.mu.Lock()
+	c.expectEndLocked(reason, PartyApplication)
+	pending := c.pendingEnd
+	c.mu.Unlock()
+	if pending != nil {
+		c.provider.persistPendingEnd(c.id, pending)
+	}
+
+	if policy.Goodbye.Text == "" && policy.Goodbye.AudioURL == "" {
+		c.hangupOrLog(ctx, reason)
+		return
+	}
+
+	twiml, err := buildVoicemailTwiML(policy.Goodbye)
+	if err == nil {
+		_, err = c.provider.client.UpdateCall(c.TraceContext(ctx), c.id, &client.UpdateCallParams{Twiml: twiml})
+	}
+	if err != nil {
+		c.provider.logger.Warn("failed to play goodbye", slog.String(logging.KeyCallSID, c.id), logging.Error(err))
+		c.hangupOrLog(ctx, reason)
+		return
+	}
+
+	// The goodbye TwiML hangs up; make sure the call ends if it does not
+	time.AfterFunc(policy.GoodbyeTimeout, func() {
+		c.mu.RLock()
+		ended := c.ended
+		c.mu.RUnlock()
+		if !ended {
+			c.hangupOrLog(context.Background(), reason)
+		}
+	})
+}
+
+// hangupOrLog hangs up, logging failures.
+func (c *Call) hangupOrLog(ctx context.Context, reason EndReason) {
+	if err := c.hangup(ctx, reason); err != nil {
+		c.provider.logger.Warn("failed to hang up call", slog.String(logging.KeyCallSID, c.id), logging.Error(err))
+	}
+}
+
+// observeAudio records when the caller last spoke.
+func (c *Call) observeAudio(frame []byte) {
+	c.mu.RLock()
+	watch := c.policySet && c.policy.MaxSilence > 0
+	threshold := c.policy.SilenceThreshold
+	c.mu.RUnlock()
+	if !watch || rms(audio.MulawDecode(frame)) < float64(threshold) {
+		return
+	}
+
+	c.mu.Lock()
+	c.lastVoice = time.Now()
+	c.mu.Unlock()
+}
+
+// noteAgentOutput records that the agent produced audio.
+func (c *Call) noteAgentOutput() {
+	c.mu.Lock()
+	c.lastAgentOutput = time.Now()
+	c.mu.Unlock()
+}
+
+// rms returns the root mean square level of samples.
+func rms(samples []int16) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
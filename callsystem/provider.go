@@ -4,10 +4,18 @@ package callsystem
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/agentplexus/omnivoice-twilio/credentials"
 	"github.com/agentplexus/omnivoice-twilio/internal/client"
+	"github.com/agentplexus/omnivoice-twilio/logging"
+	"github.com/agentplexus/omnivoice-twilio/metrics"
+	"github.com/agentplexus/omnivoice-twilio/phonenumber"
+	"github.com/agentplexus/omnivoice-twilio/tracing"
 	"github.com/agentplexus/omnivoice-twilio/transport"
 	"github.com/agentplexus/omnivoice/agent"
 	"github.com/agentplexus/omnivoice/callsystem"
@@ -17,26 +25,67 @@ import (
 // Verify interface compliance at compile time.
 var _ callsystem.CallSystem = (*Provider)(nil)
 
+// DefaultRegion is the region used to parse numbers in national format
+// unless WithDefaultRegion is set.
+const DefaultRegion = "US"
+
 // Provider implements callsystem.CallSystem using Twilio.
 type Provider struct {
 	client      *client.Client
 	config      callsystem.CallSystemConfig
 	handler     callsystem.CallHandler
+	amdHandler  MachineDetectionHandler
 	transport   *transport.Provider
 	defaultFrom string
-
-	mu    sync.RWMutex
-	calls map[string]*Call
+	amdConfig   MachineDetectionConfig
+
+	agentPipeline AgentPipelineConfig
+	inbound       InboundConfig
+	policy        CallPolicy
+	callerIDs     *CallerIDPool
+	defaultRegion string
+	preDial       *PreDialCheckConfig
+	lookups       *lookupCache
+	sipDefaults   SIPCallOptions
+	opts          options // kept for ForSubaccount
+	logger        *slog.Logger
+	tracer        tracing.Tracer
+
+	mu             sync.RWMutex
+	calls          map[string]*Call // calls owned by this instance
+	pendingStreams map[string]*transport.Connection
+	store          CallStore
+	events         *eventBus
 }
 
 // Option configures the Provider.
 type Option func(*options)
 
 type options struct {
-	accountSID  string
-	authToken   string
-	phoneNumber string
-	webhookURL  string
+	accountSID   string
+	authToken    string
+	apiKeySID    string
+	apiKeySecret string
+	credentials  credentials.Provider
+	region       string
+	edge         string
+	phoneNumber  string
+	webhookURL   string
+
+	machineDetection MachineDetectionConfig
+	store            CallStore
+	agentPipeline    AgentPipelineConfig
+	inbound          InboundConfig
+	policy           CallPolicy
+	callerIDs        *CallerIDPoolConfig
+	defaultRegion    string
+	preDial          *PreDialCheckConfig
+	sipDefaults      SIPCallOptions
+	logger           *slog.Logger
+	meter            metrics.Meter
+	tracer           tracing.Tracer
+
+	validateSignatures bool
 }
 
 // WithAccountSID sets the Twilio Account SID.
@@ -53,6 +102,54 @@ func WithAuthToken(token string) Option {
 	}
 }
 
+// WithAPIKey authenticates with an API key SID and secret instead of the
+// auth token. WithAccountSID is still required; it selects the account
+// whose resources are used.
+func WithAPIKey(sid, secret string) Option {
+	return func(o *options) {
+		o.apiKeySID = sid
+		o.apiKeySecret = secret
+	}
+}
+
+// WithCredentials supplies credentials from provider, which is consulted
+// for every API request and webhook signature check, so tokens can be
+// rotated without recreating the Provider. It takes precedence over
+// WithAuthToken and WithAPIKey.
+func WithCredentials(provider credentials.Provider) Option {
+	return func(o *options) {
+		o.credentials = provider
+	}
+}
+
+// WithLogger sets the logger for call lifecycle events, REST requests and
+// Media Streams. Phone numbers and secrets are redacted as described in
+// package logging.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithRegion sets the Twilio Region (e.g. "ie1") for data residency. Each
+// non-US region has its own auth tokens and API keys; if WithAuthToken is
+// not set, TWILIO_AUTH_TOKEN_<REGION> (e.g. TWILIO_AUTH_TOKEN_IE1) is read
+// before TWILIO_AUTH_TOKEN. Defaults to TWILIO_REGION, or US1.
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+
+// WithEdge sets the Twilio Edge location (e.g. "dublin") that API traffic
+// enters Twilio through. Defaults to TWILIO_EDGE, or the region's default
+// edge.
+func WithEdge(edge string) Option {
+	return func(o *options) {
+		o.edge = edge
+	}
+}
+
 // WithPhoneNumber sets the default outbound phone number.
 func WithPhoneNumber(number string) Option {
 	return func(o *options) {
@@ -67,42 +164,140 @@ func WithWebhookURL(url string) Option {
 	}
 }
 
+// WithDefaultRegion sets the region (ISO 3166-1 code, e.g. "GB") used to
+// parse numbers in national format. Defaults to DefaultRegion.
+func WithDefaultRegion(region string) Option {
+	return func(o *options) {
+		o.defaultRegion = region
+	}
+}
+
 // New creates a new Twilio CallSystem provider.
 func New(opts ...Option) (*Provider, error) {
-	cfg := &options{}
+	cfg := &options{defaultRegion: DefaultRegion}
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	return newProvider(cfg)
+}
+
+// newProvider creates a provider from resolved options.
+func newProvider(cfg *options) (*Provider, error) {
+	logger := logging.Logger(cfg.logger)
+	meter := metrics.OrNoop(cfg.meter)
+	tracer := tracing.OrNoop(cfg.tracer)
 
 	twilioClient, err := client.New(&client.Config{
-		AccountSID: cfg.accountSID,
-		AuthToken:  cfg.authToken,
+		Logger:       logger,
+		Meter:        meter,
+		Tracer:       tracer,
+		AccountSID:   cfg.accountSID,
+		AuthToken:    cfg.authToken,
+		APIKeySID:    cfg.apiKeySID,
+		APIKeySecret: cfg.apiKeySecret,
+		Credentials:  cfg.credentials,
+		Region:       cfg.region,
+		Edge:         cfg.edge,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Twilio client: %w", err)
 	}
 
+	if err := cfg.agentPipeline.validate(); err != nil {
+		return nil, err
+	}
+
 	// Create transport provider for Media Streams
-	tr, err := transport.New(
-		transport.WithAccountSID(cfg.accountSID),
-		transport.WithAuthToken(cfg.authToken),
-	)
+	trOpts := []transport.Option{
+		transport.WithAccountSID(twilioClient.AccountSID()),
+		transport.WithCredentials(twilioClient.CredentialsProvider()),
+		transport.WithRegion(twilioClient.Region()),
+		transport.WithEdge(twilioClient.Edge()),
+		transport.WithStreamURL(cfg.webhookURL),
+		transport.WithEarlyAudioHold(earlyAudioFrames),
+		transport.WithLogger(logger),
+		transport.WithMeter(meter),
+		transport.WithTracer(tracer),
+	}
+	if cfg.validateSignatures {
+		trOpts = append(trOpts, transport.WithSignatureValidation())
+	}
+	tr, err := transport.New(trOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transport: %w", err)
 	}
 
-	return &Provider{
-		client:      twilioClient,
-		transport:   tr,
-		defaultFrom: cfg.phoneNumber,
-		calls:       make(map[string]*Call),
+	store := cfg.store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	// Secrets live only in the client's credentials provider
+	kept := *cfg
+	kept.authToken, kept.apiKeySID, kept.apiKeySecret = "", "", ""
+	kept.credentials = twilioClient.CredentialsProvider()
+
+	p := &Provider{
+		client:         twilioClient,
+		transport:      tr,
+		defaultFrom:    cfg.phoneNumber,
+		amdConfig:      cfg.machineDetection,
+		agentPipeline:  cfg.agentPipeline.withDefaults(),
+		inbound:        cfg.inbound.withDefaults(),
+		policy:         cfg.policy.withDefaults(),
+		defaultRegion:  cfg.defaultRegion,
+		preDial:        cfg.preDial,
+		sipDefaults:    cfg.sipDefaults,
+		opts:           kept,
+		calls:          make(map[string]*Call),
+		pendingStreams: make(map[string]*transport.Connection),
+		store:          store,
+		events:         newEventBus(logger, newCallMetrics(meter)),
+		logger:         logger,
+		tracer:         tracer,
 		config: callsystem.CallSystemConfig{
-			AccountSID:  cfg.accountSID,
-			AuthToken:   cfg.authToken,
+			AccountSID:  twilioClient.AccountSID(),
 			PhoneNumber: cfg.phoneNumber,
 			WebhookURL:  cfg.webhookURL,
 		},
-	}, nil
+	}
+
+	var cacheTTL time.Duration
+	if cfg.preDial != nil {
+		cacheTTL = cfg.preDial.CacheTTL
+	}
+	p.lookups = newLookupCache(cacheTTL)
+
+	if cfg.callerIDs != nil {
+		pool := *cfg.callerIDs
+		pool.Numbers = make([]string, 0, len(cfg.callerIDs.Numbers))
+		for _, number := range cfg.callerIDs.Numbers {
+			normalized, err := phonenumber.Normalize(number, cfg.defaultRegion)
+			if err != nil {
+				return nil, fmt.Errorf("invalid caller ID: %w", err)
+			}
+			pool.Numbers = append(pool.Numbers, normalized)
+		}
+		pool.Excluded = make([]string, 0, len(cfg.callerIDs.Excluded))
+		for _, number := range cfg.callerIDs.Excluded {
+			normalized, err := phonenumber.Normalize(number, cfg.defaultRegion)
+			if err != nil {
+				return nil, fmt.Errorf("invalid excluded caller ID: %w", err)
+			}
+			pool.Excluded = append(pool.Excluded, normalized)
+		}
+
+		var load func(ctx context.Context) ([]string, error)
+		if len(pool.Numbers) == 0 {
+			load = p.loadCallerIDs
+		}
+		p.callerIDs = newCallerIDPool(pool, cfg.defaultRegion, load)
+	}
+
+	// Bind Media Streams to their calls as they start
+	tr.OnStreamStart(p.bindStream)
+
+	return p, nil
 }
 
 // Name returns the provider name.
@@ -110,11 +305,13 @@ func (p *Provider) Name() string {
 	return "twilio"
 }
 
-// Configure configures the call system.
+// Configure configures the call system. AuthToken is ignored: credentials
+// are set when the provider is created and are not kept in the config.
 func (p *Provider) Configure(config callsystem.CallSystemConfig) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	config.AuthToken = ""
 	p.config = config
 	if config.PhoneNumber != "" {
 		p.defaultFrom = config.PhoneNumber
@@ -131,23 +328,76 @@ func (p *Provider) OnIncomingCall(handler callsystem.CallHandler) {
 }
 
 // MakeCall initiates an outbound call.
+// If the call is placed but cannot be saved to the call store, both the
+// call and an error are returned.
 func (p *Provider) MakeCall(ctx context.Context, to string, opts ...callsystem.CallOption) (callsystem.Call, error) {
+	call, err := p.makeCall(ctx, to, nil, opts...)
+	if call == nil {
+		return nil, err
+	}
+	return call, err
+}
+
+// makeCall places an outbound call, with SIP options for sip: targets,
+// traced by a new call span.
+func (p *Provider) makeCall(ctx context.Context, to string, sip *SIPCallOptions, opts ...callsystem.CallOption) (*Call, error) {
+	ctx, span := p.startCallSpan(ctx, callsystem.Outbound)
+	call, err := p.placeCall(ctx, span, to, sip, opts...)
+	if call == nil {
+		span.RecordError(err)
+		span.End()
+	}
+	return call, err
+}
+
+// placeCall places an outbound call traced by span, the span in ctx.
+func (p *Provider) placeCall(ctx context.Context, span tracing.Span, to string, sip *SIPCallOptions, opts ...callsystem.CallOption) (*Call, error) {
 	// Apply options using the exported CallOptions type
 	callOpts := &callsystem.CallOptions{}
 	for _, opt := range opts {
 		opt(callOpts)
 	}
 
+	target, err := phonenumber.ParseTarget(to, p.defaultRegion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to: %w", err)
+	}
+	to = target.Value
+
+	if p.preDial != nil && target.Kind == phonenumber.KindPhone {
+		decision, err := p.preDialCheck(ctx, to)
+		if err != nil {
+			p.logger.InfoContext(ctx, "call not placed after pre-dial check", slog.String(logging.KeyTo, to), client.ErrorAttr(err))
+			return nil, err
+		}
+		for _, opt := range decision.Options {
+			opt(callOpts)
+		}
+	}
+
 	from := callOpts.From
+	if from == "" && p.callerIDs != nil {
+		selected, err := p.callerIDs.Select(ctx, to)
+		if err != nil && p.defaultFrom == "" {
+			return nil, fmt.Errorf("failed to select caller ID: %w", err)
+		}
+		from = selected
+	}
 	if from == "" {
 		from = p.defaultFrom
 	}
 	if from == "" {
 		return nil, fmt.Errorf("from number is required (use WithFrom or set default phone number)")
 	}
+	fromTarget, err := phonenumber.ParseTarget(from, p.defaultRegion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from: %w", err)
+	}
+	from = fromTarget.Value
 
-	// Build TwiML for Media Streams
-	twiml := buildMediaStreamTwiML(p.config.WebhookURL)
+	// Build TwiML for Media Streams, carrying the call's trace context
+	trace := tracing.Carrier(ctx, p.tracer)
+	twiml := buildMediaStreamTwiML(p.config.WebhookURL, trace)
 
 	params := &client.MakeCallParams{
 		To:    to,
@@ -164,8 +414,25 @@ func (p *Provider) MakeCall(ctx context.Context, to string, opts ...callsystem.C
 		params.Timeout = int(callOpts.Timeout.Seconds())
 	}
 
+	params.TimeLimit = p.policy.timeLimit()
+
+	var sipInfo *SIPInfo
+	if target.Kind == phonenumber.KindSIP {
+		if sip == nil {
+			sip = &p.sipDefaults
+		}
+		uri, err := sipURIWithHeaders(to, sip.Headers)
+		if err != nil {
+			return nil, err
+		}
+		params.To = uri
+		params.SipAuthUsername = sip.Username
+		params.SipAuthPassword = sip.Password
+		sipInfo = &SIPInfo{URI: to, Headers: copyHeaders(sip.Headers)}
+	}
+
 	if callOpts.MachineDetect {
-		params.MachineDetection = "Enable"
+		p.amdConfig.apply(params)
 	}
 
 	if callOpts.Record {
@@ -175,35 +442,39 @@ func (p *Provider) MakeCall(ctx context.Context, to string, opts ...callsystem.C
 
 	twilioCall, err := p.client.MakeCall(ctx, params)
 	if err != nil {
+		p.logger.WarnContext(ctx, "failed to make call", slog.String(logging.KeyTo, to), client.ErrorAttr(err))
 		return nil, fmt.Errorf("failed to make call: %w", err)
 	}
+	span.SetAttribute(tracing.AttrCallSID, twilioCall.SID)
 
+	now := time.Now()
 	call := &Call{
-		id:        twilioCall.SID,
-		direction: callsystem.Outbound,
-		status:    mapCallStatus(twilioCall.Status),
-		from:      from,
-		to:        to,
-		startTime: time.Now(),
-		provider:  p,
+		id:          twilioCall.SID,
+		direction:   callsystem.Outbound,
+		status:      mapCallStatus(twilioCall.Status),
+		from:        from,
+		to:          to,
+		createdTime: now,
+		startTime:   now,
+		sip:         sipInfo,
+		provider:    p,
+		span:        span,
+		trace:       trace,
+
+		timeLimitSent: params.TimeLimit > 0,
 	}
 
-	p.mu.Lock()
-	p.calls[call.id] = call
-	p.mu.Unlock()
+	err = p.track(ctx, call)
 
-	return call, nil
+	return call, err
 }
 
 // GetCall retrieves a call by ID.
 func (p *Provider) GetCall(ctx context.Context, callID string) (callsystem.Call, error) {
-	// Check local cache first
-	p.mu.RLock()
-	if call, ok := p.calls[callID]; ok {
-		p.mu.RUnlock()
+	// Check local calls and the call store first
+	if call, err := p.lookupCall(ctx, callID); err == nil {
 		return call, nil
 	}
-	p.mu.RUnlock()
 
 	// Fetch from Twilio
 	twilioCall, err := p.client.GetCall(ctx, callID)
@@ -211,42 +482,46 @@ func (p *Provider) GetCall(ctx context.Context, callID string) (callsystem.Call,
 		return nil, fmt.Errorf("failed to get call: %w", err)
 	}
 
-	call := &Call{
-		id:        twilioCall.SID,
-		direction: mapDirection(twilioCall.Direction),
-		status:    mapCallStatus(twilioCall.Status),
-		from:      twilioCall.From,
-		to:        twilioCall.To,
-		provider:  p,
-	}
-
-	return call, nil
+	return p.callFromAPI(twilioCall), nil
 }
 
-// ListCalls lists active calls.
+// ListCalls lists active calls, including calls owned by other provider
+// instances sharing the call store.
 func (p *Provider) ListCalls(ctx context.Context) ([]callsystem.Call, error) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+	records, err := p.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list calls: %w", err)
+	}
 
-	calls := make([]callsystem.Call, 0, len(p.calls))
-	for _, call := range p.calls {
-		calls = append(calls, call)
+	calls := make([]callsystem.Call, 0, len(records))
+	for _, record := range records {
+		if record.Ended {
+			continue
+		}
+		calls = append(calls, p.callFromRecord(record))
 	}
 	return calls, nil
 }
 
-// Close shuts down the call system.
+// Close shuts down the call system, hanging up calls owned by this instance.
 func (p *Provider) Close() error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	calls := make([]*Call, 0, len(p.calls))
+	for _, call := range p.calls {
+		calls = append(calls, call)
+	}
+	p.mu.Unlock()
 
 	// Hangup all active calls
 	ctx := context.Background()
-	for _, call := range p.calls {
-		_ = call.Hangup(ctx)
+	for _, call := range calls {
+		if err := call.hangup(ctx, EndReasonProviderClosed); err != nil {
+			p.logger.Error("failed to hang up call on close", slog.String(logging.KeyCallSID, call.id), logging.Error(err))
+		}
+		p.forget(ctx, call.id)
 	}
 
-	p.calls = make(map[string]*Call)
+	p.events.close()
 
 	if p.transport != nil {
 		return p.transport.Close()
@@ -257,45 +532,109 @@ func (p *Provider) Close() error {
 
 // HandleIncomingWebhook processes a Twilio incoming call webhook.
 // This should be called from your HTTP handler.
+//
+// By default the returned TwiML connects the Media Stream. With a deferred
+// InboundConfig the call stays ringing until the application calls Answer,
+// Reject or Redirect (from the handler or later), and the TwiML reflects
+// that decision; without a hold URL this method blocks until then.
 func (p *Provider) HandleIncomingWebhook(callSID, from, to string) (callsystem.Call, string, error) {
+	return p.handleIncoming(callSID, from, to, nil)
+}
+
+// handleIncoming processes an incoming call, with SIP details for calls
+// from a SIP domain.
+func (p *Provider) handleIncoming(callSID, from, to string, sip *SIPInfo) (callsystem.Call, string, error) {
+	ctx, span := p.startCallSpan(context.Background(), callsystem.Inbound)
+	span.SetAttribute(tracing.AttrCallSID, callSID)
+
+	now := time.Now()
 	call := &Call{
-		id:        callSID,
-		direction: callsystem.Inbound,
-		status:    callsystem.StatusRinging,
-		from:      from,
-		to:        to,
-		startTime: time.Now(),
-		provider:  p,
+		id:          callSID,
+		direction:   callsystem.Inbound,
+		status:      callsystem.StatusRinging,
+		from:        from,
+		to:          to,
+		createdTime: now,
+		startTime:   now,
+		ringTime:    now,
+		sip:         sip,
+		provider:    p,
+		span:        span,
+		trace:       tracing.Carrier(ctx, p.tracer),
 	}
 
-	p.mu.Lock()
-	p.calls[callSID] = call
+	var decision chan answerDecision
+	if p.inbound.Defer {
+		decision = make(chan answerDecision, 1)
+		call.decision = decision
+	}
+
+	if err := p.track(ctx, call); err != nil {
+		span.RecordError(err)
+		p.abandon(ctx, call)
+		return nil, "", err
+	}
+
+	p.mu.RLock()
 	handler := p.handler
-	p.mu.Unlock()
+	p.mu.RUnlock()
 
 	// Call the handler
 	if handler != nil {
 		if err := handler(call); err != nil {
+			p.logger.Warn("incoming call handler failed", slog.String(logging.KeyCallSID, callSID), logging.Error(err))
+			span.RecordError(err)
+			p.abandon(ctx, call)
 			return nil, "", err
 		}
 	}
 
-	// Return TwiML for Media Streams
-	twiml := buildMediaStreamTwiML(p.config.WebhookURL)
-	return call, twiml, nil
+	if !p.inbound.Defer {
+		// Return TwiML for Media Streams
+		twiml := buildMediaStreamTwiML(p.config.WebhookURL, call.trace)
+		return call, twiml, nil
+	}
+
+	if p.inbound.HoldURL != "" {
+		if p.holdCall(call) {
+			return call, buildHoldTwiML(p.inbound.HoldURL), nil
+		}
+		// Decided within the handler; respond with the decision directly
+		return call, call.decisionTwiML(<-decision, false), nil
+	}
+
+	return call, p.awaitDecision(call, decision), nil
+}
+
+// abandon ends an incoming call that could not be set up, since Twilio
+// fails the call when its webhook errors.
+func (p *Provider) abandon(ctx context.Context, call *Call) {
+	call.mu.Lock()
+	call.status = callsystem.StatusFailed
+	endedNow := !call.ended
+	call.ended = true
+	if call.endTime.IsZero() {
+		call.endTime = time.Now()
+	}
+	call.endLocked(EndReasonFailed, PartyApplication)
+	endInfo := copyEndInfo(call.endInfo)
+	call.mu.Unlock()
+
+	p.forget(ctx, call.id)
+	if endedNow {
+		p.publish(EventCallEnded, call, endInfo)
+	}
 }
 
 // HandleStatusCallback processes a Twilio status callback webhook.
+// Use HandleStatusCallbackForm to also record durations, timestamps and
+// error details and to handle out-of-order delivery.
 func (p *Provider) HandleStatusCallback(callSID, status string) {
-	p.mu.Lock()
-	call, ok := p.calls[callSID]
-	if ok {
-		call.status = mapCallStatus(status)
-		if call.status == callsystem.StatusEnded {
-			delete(p.calls, callSID)
-		}
-	}
-	p.mu.Unlock()
+	p.handleStatus(&StatusCallback{
+		CallSID:        callSID,
+		CallStatus:     status,
+		SequenceNumber: -1,
+	})
 }
 
 // Transport returns the transport provider for Media Streams.
@@ -316,6 +655,47 @@ type Call struct {
 	mu        sync.RWMutex
 	transport omnitransport.Connection
 	agent     agent.Session
+	amd       *MachineDetectionResult
+	amdReady  chan struct{}
+
+	unobserve func()
+	pipeline  *agentPipeline
+
+	decision    chan answerDecision
+	held        bool
+	answerTimer *time.Timer
+
+	createdTime      time.Time
+	ringTime         time.Time
+	answerTime       time.Time
+	endTime          time.Time
+	billableDuration time.Duration
+	billing          *Billing
+
+	endInfo      *EndInfo
+	pendingEnd   *EndInfo
+	stopExpected bool // the application stopped the Media Stream, not Twilio
+
+	sip *SIPInfo
+
+	span  tracing.Span      // nil unless this instance started the call
+	trace map[string]string // trace context of the call's span
+
+	gauged        bool // counted in the active calls gauge on this instance
+	timeLimitSent bool // the policy's TimeLimit has been sent to Twilio
+
+	policy          CallPolicy
+	policySet       bool
+	watching        bool
+	policyEnding    bool
+	lastVoice       time.Time
+	lastAgentOutput time.Time
+
+	transitions  []StatusTransition
+	lastSequence int
+	hasSequence  bool
+	ended        bool
+	finalStatus  string
 }
 
 // ID returns the call identifier.
@@ -350,94 +730,353 @@ func (c *Call) StartTime() time.Time {
 	return c.startTime
 }
 
-// Duration returns the call duration.
+// CreatedTime returns when the call was created.
+func (c *Call) CreatedTime() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.createdTime
+}
+
+// Duration returns the call duration from start until it ended, or until
+// now for calls in progress. It stops growing once the call ends.
 func (c *Call) Duration() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.startTime.IsZero() {
+		return 0
+	}
+	if !c.endTime.IsZero() {
+		return c.endTime.Sub(c.startTime)
+	}
+	if c.ended && len(c.transitions) > 0 {
+		return c.transitions[len(c.transitions)-1].Time.Sub(c.startTime)
+	}
 	return time.Since(c.startTime)
 }
 
-// Answer answers an inbound call.
+// BillableDuration returns the call duration reported by Twilio when the
+// call completed, or zero before then. Use Billing for the price.
+func (c *Call) BillableDuration() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.billableDuration == 0 && c.billing != nil {
+		return c.billing.Duration
+	}
+	return c.billableDuration
+}
+
+// Answer answers an inbound call. For a deferred call (see InboundConfig)
+// this connects the caller to the Media Stream. Answering a call that is
+// already answered does nothing. When several provider instances share a
+// call store, only one of them can answer a given call; Answer fails if
+// the call is not ringing in the store (answered elsewhere or ended) or is
+// no longer stored.
 func (c *Call) Answer(ctx context.Context) error {
+	if pending, err := c.decide(ctx, answerDecision{kind: decisionAnswer}); pending || err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	answered := c.status == callsystem.StatusAnswered && !c.ended
+	c.mu.RUnlock()
+	if answered {
+		return nil
+	}
+
+	swapped, err := c.provider.store.CompareAndSwapStatus(ctx, c.id, callsystem.StatusRinging, callsystem.StatusAnswered)
+	if err != nil {
+		return fmt.Errorf("failed to answer: %w", err)
+	}
+	if !swapped {
+		return fmt.Errorf("call %s is not ringing", c.id)
+	}
+
 	c.mu.Lock()
 	c.status = callsystem.StatusAnswered
+	if c.answerTime.IsZero() {
+		c.answerTime = time.Now()
+	}
 	c.mu.Unlock()
 	return nil
 }
 
 // Hangup ends the call.
 func (c *Call) Hangup(ctx context.Context) error {
-	_, err := c.provider.client.HangupCall(ctx, c.id)
+	reason := EndReasonAPIHangup
+	if c.AnsweredBy().IsMachine() {
+		reason = EndReasonMachineDetected
+	}
+	return c.hangup(ctx, reason)
+}
+
+// hangup ends the call, recording reason as why it ended.
+func (c *Call) hangup(ctx context.Context, reason EndReason) error {
+	c.setStopExpected(true)
+	_, err := c.provider.client.HangupCall(c.TraceContext(ctx), c.id)
 	if err != nil {
+		c.setStopExpected(false)
 		return fmt.Errorf("failed to hangup: %w", err)
 	}
 
+	c.teardownAgent(ctx)
+
 	c.mu.Lock()
 	c.status = callsystem.StatusEnded
-	if c.transport != nil {
-		_ = c.transport.Close()
+	endedNow := !c.ended
+	c.ended = true
+	if c.endTime.IsZero() {
+		c.endTime = time.Now()
 	}
+	c.endLocked(reason, PartyApplication)
+	endInfo := copyEndInfo(c.endInfo)
+	conn := c.transport
 	c.mu.Unlock()
 
+	// Closing the stream notifies handleTransportEvent, which locks c.mu
+	if conn != nil {
+		_ = conn.Close()
+	}
+
+	err = c.provider.store.Update(ctx, c.id, func(record *CallRecord) error {
+		record.Status = callsystem.StatusEnded
+		record.Ended = true
+		if record.End == nil {
+			record.End = endInfo
+		}
+		record.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		c.provider.logger.Warn("failed to store call end", slog.String(logging.KeyCallSID, c.id), logging.Error(err))
+	}
+
+	if endedNow {
+		c.provider.publish(EventCallEnded, c, endInfo)
+	}
+
 	return nil
 }
 
-// Transport returns the underlying transport connection.
+// Transport returns the underlying transport connection, or nil while no
+// Media Stream is attached.
 func (c *Call) Transport() omnitransport.Connection {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.transport
 }
 
-// SetTransport sets the transport connection (called when Media Streams connects).
+// Transfer connects the call to target, ending its Media Stream. Target is
+// a phone number, a sip: URI or a client: identity (e.g. a supervisor in a
+// browser), dialed with TwiML <Dial>.
+func (c *Call) Transfer(ctx context.Context, target string) error {
+	parsed, err := phonenumber.ParseTarget(target, c.provider.defaultRegion)
+	if err != nil {
+		return fmt.Errorf("invalid transfer target: %w", err)
+	}
+
+	twiml, err := transport.DialTwiML(parsed.Value)
+	if err != nil {
+		return err
+	}
+
+	c.setStopExpected(true)
+	if _, err := c.provider.client.UpdateCall(c.TraceContext(ctx), c.id, &client.UpdateCallParams{Twiml: twiml}); err != nil {
+		c.setStopExpected(false)
+		return fmt.Errorf("failed to transfer call: %w", err)
+	}
+	return nil
+}
+
+// SetTransport sets the transport connection. The provider calls it
+// automatically when the call's Media Stream starts. An attached agent is
+// connected to the new stream.
 func (c *Call) SetTransport(conn omnitransport.Connection) {
 	c.mu.Lock()
+	c.stopPipelineLocked()
 	c.transport = conn
+	c.stopExpected = false
+	if c.unobserve != nil {
+		c.unobserve()
+		c.unobserve = nil
+	}
+	if tc, ok := conn.(*transport.Connection); ok {
+		unobserveEvents := tc.OnEvent(c.handleTransportEvent)
+		unobserveAudio := tc.OnAudio(c.observeAudio)
+		c.unobserve = func() {
+			unobserveEvents()
+			unobserveAudio()
+		}
+		c.lastVoice = time.Now()
+		c.lastAgentOutput = c.lastVoice
+	}
+	if conn != nil && c.pendingEnd != nil && c.pendingEnd.Reason == EndReasonStreamDropped {
+		c.pendingEnd = nil
+	}
+	c.startPipelineLocked()
+	c.mu.Unlock()
+
+	if conn != nil {
+		c.provider.publish(EventStreamAttached, c, conn)
+	}
+}
+
+// handleTransportEvent republishes Media Stream events as call events.
+func (c *Call) handleTransportEvent(event omnitransport.Event) {
+	switch event.Type {
+	case omnitransport.EventDTMF:
+		c.provider.publish(EventDTMF, c, event.Data)
+	case transport.EventDTMFSequence:
+		c.provider.publish(EventDTMFSequence, c, event.Data)
+	case omnitransport.EventDisconnected:
+		c.mu.Lock()
+		c.stopPipelineLocked()
+		c.transport = nil
+		var remoteEnd bool
+		switch event.Data {
+		case transport.DisconnectDropped:
+			// Without a stream the <Connect> verb ends and Twilio hangs up
+			c.expectEndLocked(EndReasonStreamDropped, PartyApplication)
+		case transport.DisconnectStopped:
+			// Twilio stops the stream when the call ends, unless the
+			// application replaced the call's TwiML or hung up itself
+			remoteEnd = !c.ended && !c.stopExpected && c.pendingEnd == nil
+			if remoteEnd {
+				c.status = callsystem.StatusEnded
+				c.ended = true
+				if c.endTime.IsZero() {
+					c.endTime = time.Now()
+				}
+				c.endLocked(EndReasonRemoteHangup, c.remoteParty())
+			}
+		}
+		pending := c.pendingEnd
+		endInfo := copyEndInfo(c.endInfo)
+		c.mu.Unlock()
+
+		if pending != nil {
+			c.provider.persistPendingEnd(c.id, pending)
+		}
+		c.provider.publish(EventStreamDetached, c, event.Data)
+		if remoteEnd {
+			c.endOnStreamStop(endInfo)
+		}
+	}
+}
+
+// endOnStreamStop finishes ending a call whose stream Twilio stopped
+// because the remote party hung up.
+func (c *Call) endOnStreamStop(endInfo *EndInfo) {
+	ctx := context.Background()
+	c.teardownAgent(ctx)
+
+	err := c.provider.store.Update(ctx, c.id, func(record *CallRecord) error {
+		record.Status = callsystem.StatusEnded
+		record.Ended = true
+		if record.End == nil {
+			record.End = endInfo
+		}
+		record.UpdatedAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		c.provider.logger.Warn("failed to store call end", slog.String(logging.KeyCallSID, c.id), logging.Error(err))
+	}
+
+	c.provider.forget(ctx, c.id)
+	c.provider.publish(EventCallEnded, c, endInfo)
+}
+
+// setStopExpected records whether the application is stopping the call's
+// Media Stream, by hanging up or replacing the call's TwiML, so that the
+// stop message is not taken for a remote hangup.
+func (c *Call) setStopExpected(expected bool) {
+	c.mu.Lock()
+	c.stopExpected = expected
 	c.mu.Unlock()
 }
 
-// AttachAgent attaches a voice agent to handle the call.
+// AttachAgent attaches a voice agent to handle the call. The session is
+// started and connected to the call's Media Stream (or to the stream once
+// it starts): caller audio is sent to the session, session audio is played
+// to the caller, interruptions clear queued playback and DTMF input is
+// sent as text. The pipeline consumes the session's Events channel;
+// session events are republished as EventAgentEvent.
 func (c *Call) AttachAgent(ctx context.Context, session agent.Session) error {
+	c.mu.RLock()
+	attached := c.agent != nil
+	c.mu.RUnlock()
+	if attached {
+		return fmt.Errorf("agent already attached; detach it first")
+	}
+
+	// Start the agent session
+	if err := session.Start(ctx); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	c.agent = session
+	c.lastAgentOutput = time.Now()
+	c.startPipelineLocked()
 	c.mu.Unlock()
 
-	// Start the agent session
-	return session.Start(ctx)
+	c.provider.publish(EventAgentAttached, c, session)
+	return nil
 }
 
-// DetachAgent detaches the voice agent.
+// DetachAgent disconnects and stops the voice agent.
 func (c *Call) DetachAgent(ctx context.Context) error {
 	c.mu.Lock()
 	session := c.agent
 	c.agent = nil
+	c.stopPipelineLocked()
 	c.mu.Unlock()
 
-	if session != nil {
-		return session.Stop(ctx)
+	if session == nil {
+		return nil
 	}
-	return nil
+
+	err := session.Stop(ctx)
+	c.provider.publish(EventAgentDetached, c, session)
+	return err
 }
 
-// buildMediaStreamTwiML creates TwiML for Media Streams.
-func buildMediaStreamTwiML(webhookURL string) string {
+// buildMediaStreamTwiML creates TwiML for Media Streams. The trace context
+// is passed as custom parameters, which Twilio returns in the stream's
+// start message.
+func buildMediaStreamTwiML(webhookURL string, trace map[string]string) string {
 	streamURL := webhookURL
 	if streamURL == "" {
 		streamURL = "wss://your-server.com/media-stream"
 	}
 
+	keys := make([]string, 0, len(trace))
+	for key := range trace {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&params, "\n            <Parameter name=\"%s\" value=\"%s\"/>", escapeXML(key), escapeXML(trace[key]))
+	}
+
 	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <Response>
     <Connect>
         <Stream url="%s">
-            <Parameter name="direction" value="both"/>
+            <Parameter name="direction" value="both"/>%s
         </Stream>
     </Connect>
-</Response>`, streamURL)
+</Response>`, streamURL, params.String())
 }
 
 // mapCallStatus maps Twilio status to OmniVoice status.
 func mapCallStatus(status string) callsystem.CallStatus {
 	switch status {
-	case "queued", "ringing":
+	case "queued", "initiated", "ringing":
 		return callsystem.StatusRinging
 	case "in-progress":
 		return callsystem.StatusAnswered
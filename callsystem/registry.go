@@ -0,0 +1,375 @@
+package callsystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/agentplexus/omnivoice-twilio/phonenumber"
+	"github.com/agentplexus/omnivoice-twilio/transport"
+	"github.com/agentplexus/omnivoice/callsystem"
+)
+
+// ErrUnknownTenant is returned when a request cannot be routed to a
+// registered tenant.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// ErrTenantAtCapacity is returned when a tenant already has its maximum
+// number of concurrent calls.
+var ErrTenantAtCapacity = errors.New("tenant at call capacity")
+
+// Tenant is a customer served by a Registry, with its own Provider.
+type Tenant struct {
+	// ID identifies the tenant in the registry.
+	ID string
+
+	// Provider handles the tenant's calls. Its account SID routes
+	// webhooks and media streams to the tenant.
+	Provider *Provider
+
+	// Numbers are the tenant's phone numbers. Incoming calls to them are
+	// routed to the tenant, which is needed when several tenants share a
+	// Twilio account.
+	Numbers []string
+
+	// MaxConcurrentCalls limits the tenant's active calls on this
+	// instance, inbound and outbound. Inbound calls over the limit are
+	// rejected as busy. Zero means no limit.
+	MaxConcurrentCalls int
+
+	// Handler, if set, is registered with the Provider for incoming calls.
+	Handler callsystem.CallHandler
+}
+
+// tenantEntry is a registered tenant.
+type tenantEntry struct {
+	Tenant
+	accountSID string
+
+	mu      sync.Mutex
+	pending int // admitted calls not yet tracked by the provider
+}
+
+// admit reserves a call slot. The returned release must be called once
+// the call is tracked or has failed.
+func (t *tenantEntry) admit() (release func(), err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.MaxConcurrentCalls > 0 && t.Provider.activeCalls()+t.pending >= t.MaxConcurrentCalls {
+		return nil, fmt.Errorf("tenant %s: %w", t.ID, ErrTenantAtCapacity)
+	}
+	t.pending++
+	return func() {
+		t.mu.Lock()
+		t.pending--
+		t.mu.Unlock()
+	}, nil
+}
+
+// Registry routes webhooks and media streams for several tenants, each
+// with its own Provider, by AccountSid and called number.
+type Registry struct {
+	mu       sync.RWMutex
+	tenants  map[string]*tenantEntry
+	byNumber map[string]*tenantEntry
+	router   *transport.StreamRouter
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	r := &Registry{
+		tenants:  make(map[string]*tenantEntry),
+		byNumber: make(map[string]*tenantEntry),
+	}
+	r.router = transport.NewStreamRouter(r.routeStream)
+	return r
+}
+
+// Register adds a tenant. Tenant IDs and numbers must be unique.
+func (r *Registry) Register(tenant Tenant) error {
+	if tenant.ID == "" {
+		return fmt.Errorf("tenant ID is required")
+	}
+	if tenant.Provider == nil {
+		return fmt.Errorf("tenant %s has no provider", tenant.ID)
+	}
+
+	entry := &tenantEntry{
+		Tenant:     tenant,
+		accountSID: tenant.Provider.client.AccountSID(),
+	}
+	entry.Numbers = make([]string, 0, len(tenant.Numbers))
+	for _, number := range tenant.Numbers {
+		normalized, err := phonenumber.Normalize(number, tenant.Provider.defaultRegion)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+		entry.Numbers = append(entry.Numbers, normalized)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenants[tenant.ID]; ok {
+		return fmt.Errorf("tenant %s is already registered", tenant.ID)
+	}
+	for _, number := range entry.Numbers {
+		if other, ok := r.byNumber[number]; ok {
+			return fmt.Errorf("number %s is already registered to tenant %s", number, other.ID)
+		}
+	}
+
+	r.tenants[tenant.ID] = entry
+	for _, number := range entry.Numbers {
+		r.byNumber[number] = entry
+	}
+
+	if tenant.Handler != nil {
+		tenant.Provider.OnIncomingCall(tenant.Handler)
+	}
+	return nil
+}
+
+// Unregister removes a tenant and returns its Provider, which is not
+// closed.
+func (r *Registry) Unregister(id string) (*Provider, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.tenants[id]
+	if !ok {
+		return nil, false
+	}
+	delete(r.tenants, id)
+	for _, number := range entry.Numbers {
+		delete(r.byNumber, number)
+	}
+	return entry.Provider, true
+}
+
+// Provider returns a tenant's Provider.
+func (r *Registry) Provider(id string) (*Provider, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.tenants[id]
+	if !ok {
+		return nil, false
+	}
+	return entry.Provider, true
+}
+
+// Tenants returns the IDs of registered tenants, sorted.
+func (r *Registry) Tenants() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.tenants))
+	for id := range r.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Route returns the tenant for a request from accountSID concerning one
+// of numbers: the tenant owning the first matching number in that
+// account, or else the only tenant in that account.
+func (r *Registry) Route(accountSID string, numbers ...string) (string, *Provider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, err := r.routeLocked(accountSID, "", numbers...)
+	if err != nil {
+		return "", nil, err
+	}
+	return entry.ID, entry.Provider, nil
+}
+
+// routeLocked finds a tenant by call ownership, then by number, then by
+// account. It must be called with r.mu held.
+func (r *Registry) routeLocked(accountSID, callSID string, numbers ...string) (*tenantEntry, error) {
+	if callSID != "" {
+		for _, entry := range r.tenants {
+			if entry.Provider.owns(callSID) {
+				return entry, nil
+			}
+		}
+	}
+
+	for _, number := range numbers {
+		if entry, ok := r.byNumber[number]; ok && (accountSID == "" || entry.accountSID == accountSID) {
+			return entry, nil
+		}
+	}
+
+	var match *tenantEntry
+	matches := 0
+	for _, entry := range r.tenants {
+		if accountSID != "" && entry.accountSID == accountSID {
+			match = entry
+			matches++
+		}
+	}
+
+	switch matches {
+	case 0:
+		return nil, fmt.Errorf("account %s: %w", accountSID, ErrUnknownTenant)
+	case 1:
+		return match, nil
+	default:
+		return nil, fmt.Errorf("account %s is shared by %d tenants and no number matched: %w", accountSID, matches, ErrUnknownTenant)
+	}
+}
+
+// route finds the tenant for a webhook.
+func (r *Registry) route(form url.Values, numbers ...string) (*tenantEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.routeLocked(form.Get("AccountSid"), form.Get("CallSid"), numbers...)
+}
+
+// routeStream chooses the Provider for a media stream.
+func (r *Registry) routeStream(info transport.StreamInfo) *transport.Provider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, err := r.routeLocked(info.AccountSID, info.CallSID)
+	if err != nil {
+		return nil
+	}
+	return entry.Provider.transport
+}
+
+// ValidateWebhook routes a webhook request to its tenant and checks its
+// signature with the tenant's credentials. See Provider.ValidateWebhook.
+func (r *Registry) ValidateWebhook(req *http.Request, publicURL string) error {
+	if err := req.ParseForm(); err != nil {
+		return fmt.Errorf("failed to parse webhook: %w", err)
+	}
+
+	entry, err := r.route(req.Form, req.Form.Get("To"), req.Form.Get("From"))
+	if err != nil {
+		return err
+	}
+	return entry.Provider.ValidateWebhook(req, publicURL)
+}
+
+// HandleIncomingWebhookForm routes an incoming call webhook to its tenant
+// by AccountSid and To number. If the tenant is at capacity, it returns
+// TwiML rejecting the call as busy, which should still be sent to Twilio,
+// along with an error matching ErrTenantAtCapacity.
+func (r *Registry) HandleIncomingWebhookForm(form url.Values) (callsystem.Call, string, error) {
+	entry, err := r.route(form, form.Get("To"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	release, err := entry.admit()
+	if err != nil {
+		return nil, twimlResponse(fmt.Sprintf(`<Reject reason="%s"/>`, RejectBusy)), err
+	}
+	defer release()
+
+	return entry.Provider.HandleIncomingWebhookForm(form)
+}
+
+// HandleStatusCallbackForm routes a status callback to the tenant that
+// owns the call.
+func (r *Registry) HandleStatusCallbackForm(form url.Values) error {
+	entry, err := r.route(form, form.Get("To"), form.Get("From"))
+	if err != nil {
+		return err
+	}
+	return entry.Provider.HandleStatusCallbackForm(form)
+}
+
+// HandleMachineDetectionCallback routes an asynchronous AMD callback to
+// the tenant that owns the call.
+func (r *Registry) HandleMachineDetectionCallback(form url.Values) error {
+	entry, err := r.route(form)
+	if err != nil {
+		return err
+	}
+	entry.Provider.HandleMachineDetectionCallback(form)
+	return nil
+}
+
+// HandleWebSocket handles a Media Streams WebSocket, routing the stream to
+// its tenant by account and call.
+func (r *Registry) HandleWebSocket(w http.ResponseWriter, req *http.Request, listenerPath string) error {
+	return r.router.HandleWebSocket(w, req, listenerPath)
+}
+
+// MakeCall places an outbound call for a tenant, enforcing its concurrency
+// limit.
+func (r *Registry) MakeCall(ctx context.Context, tenantID, to string, opts ...callsystem.CallOption) (callsystem.Call, error) {
+	r.mu.RLock()
+	entry, ok := r.tenants[tenantID]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("tenant %s: %w", tenantID, ErrUnknownTenant)
+	}
+
+	release, err := entry.admit()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return entry.Provider.MakeCall(ctx, to, opts...)
+}
+
+// Close closes all tenants' Providers.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	entries := make([]*tenantEntry, 0, len(r.tenants))
+	for _, entry := range r.tenants {
+		entries = append(entries, entry)
+	}
+	r.tenants = make(map[string]*tenantEntry)
+	r.byNumber = make(map[string]*tenantEntry)
+	r.mu.Unlock()
+
+	var errs []error
+	for _, entry := range entries {
+		if err := entry.Provider.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", entry.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// activeCalls returns the number of calls owned by this instance that have
+// not ended.
+func (p *Provider) activeCalls() int {
+	p.mu.RLock()
+	calls := make([]*Call, 0, len(p.calls))
+	for _, call := range p.calls {
+		calls = append(calls, call)
+	}
+	p.mu.RUnlock()
+
+	n := 0
+	for _, call := range calls {
+		call.mu.RLock()
+		if !call.ended {
+			n++
+		}
+		call.mu.RUnlock()
+	}
+	return n
+}
+
+// owns reports whether the call is owned by this instance.
+func (p *Provider) owns(callSID string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.calls[callSID]
+	return ok
+}
@@ -0,0 +1,167 @@
+package callsystem
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/agentplexus/omnivoice/callsystem"
+)
+
+// sipHeaderPrefix prefixes SIP headers in Twilio webhook parameters.
+const sipHeaderPrefix = "SipHeader_"
+
+// SIPCallOptions configures calls to sip: targets.
+type SIPCallOptions struct {
+	// Username and Password answer digest challenges from the target.
+	Username string
+	Password string
+
+	// Headers are sent as custom SIP headers. Names must start with "X-".
+	Headers map[string]string
+}
+
+// WithSIPCredentials sets the credentials used for sip: targets dialed
+// with MakeCall.
+func WithSIPCredentials(username, password string) Option {
+	return func(o *options) {
+		o.sipDefaults.Username = username
+		o.sipDefaults.Password = password
+	}
+}
+
+// SIPInfo describes the SIP side of a call.
+type SIPInfo struct {
+	// URI is the dialed SIP URI, for outbound calls.
+	URI string `json:"uri,omitempty"`
+
+	// CallID is the SIP Call-ID, for inbound calls.
+	CallID string `json:"call_id,omitempty"`
+
+	// Domain and DomainSID identify the Twilio SIP domain an inbound call
+	// arrived on.
+	Domain    string `json:"domain,omitempty"`
+	DomainSID string `json:"domain_sid,omitempty"`
+
+	// SourceIP is the address the inbound INVITE came from.
+	SourceIP string `json:"source_ip,omitempty"`
+
+	// Headers are the custom SIP headers received with an inbound call,
+	// or sent with an outbound one.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// MakeSIPCall calls a sip: URI with per-call credentials and headers.
+func (p *Provider) MakeSIPCall(ctx context.Context, uri string, sip SIPCallOptions, opts ...callsystem.CallOption) (callsystem.Call, error) {
+	if !strings.HasPrefix(strings.ToLower(uri), "sip:") && !strings.HasPrefix(strings.ToLower(uri), "sips:") {
+		return nil, fmt.Errorf("not a SIP URI: %s", uri)
+	}
+
+	call, err := p.makeCall(ctx, uri, &sip, opts...)
+	if call == nil {
+		return nil, err
+	}
+	return call, err
+}
+
+// HandleIncomingWebhookForm processes a Twilio incoming call webhook from
+// its form parameters. Unlike HandleIncomingWebhook it also records SIP
+// details (SipCallId, SipDomain, SipHeader_*) for calls from a SIP domain.
+func (p *Provider) HandleIncomingWebhookForm(form url.Values) (callsystem.Call, string, error) {
+	callSID := form.Get("CallSid")
+	if callSID == "" {
+		return nil, "", fmt.Errorf("missing CallSid")
+	}
+	return p.handleIncoming(callSID, form.Get("From"), form.Get("To"), parseSIPInfo(form))
+}
+
+// parseSIPInfo extracts SIP parameters from a webhook, or returns nil for
+// calls that did not arrive over SIP.
+func parseSIPInfo(form url.Values) *SIPInfo {
+	info := &SIPInfo{
+		CallID:    form.Get("SipCallId"),
+		Domain:    form.Get("SipDomain"),
+		DomainSID: form.Get("SipDomainSid"),
+		SourceIP:  form.Get("SipSourceIp"),
+	}
+	for key, values := range form {
+		if name, ok := strings.CutPrefix(key, sipHeaderPrefix); ok && name != "" && len(values) > 0 {
+			if info.Headers == nil {
+				info.Headers = make(map[string]string)
+			}
+			info.Headers[name] = values[0]
+		}
+	}
+
+	if info.CallID == "" && info.DomainSID == "" && info.Headers == nil {
+		return nil
+	}
+	return info
+}
+
+// sipURIWithHeaders appends custom headers to a SIP URI as Twilio expects:
+// sip:user@host?X-Name=value&...
+func sipURIWithHeaders(uri string, headers map[string]string) (string, error) {
+	if len(headers) == 0 {
+		return uri, nil
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		if !strings.HasPrefix(strings.ToUpper(name), "X-") {
+			return "", fmt.Errorf("SIP header %q must start with X-", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	params := make([]string, 0, len(names))
+	for _, name := range names {
+		params = append(params, url.QueryEscape(name)+"="+url.QueryEscape(headers[name]))
+	}
+
+	sep := "?"
+	if strings.Contains(uri, "?") {
+		sep = "&"
+	}
+	return uri + sep + strings.Join(params, "&"), nil
+}
+
+// copyHeaders returns a copy of headers, or nil.
+func copyHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(headers))
+	for name, value := range headers {
+		c[name] = value
+	}
+	return c
+}
+
+// SIP returns the SIP details of the call, or nil for calls that did not
+// use SIP.
+func (c *Call) SIP() *SIPInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.sip == nil {
+		return nil
+	}
+	info := *c.sip
+	info.Headers = copyHeaders(c.sip.Headers)
+	return &info
+}
+
+// SIPHeaders returns the call's custom SIP headers, or nil.
+func (c *Call) SIPHeaders() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.sip == nil {
+		return nil
+	}
+	return copyHeaders(c.sip.Headers)
+}
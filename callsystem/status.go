@@ -0,0 +1,259 @@
+package callsystem
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// StatusCallback is a parsed Twilio call status callback.
+type StatusCallback struct {
+	CallSID         string
+	AccountSID      string
+	ParentCallSID   string
+	CallStatus      string // Raw Twilio status (e.g. "in-progress")
+	Direction       string
+	From            string
+	To              string
+	CallDuration    time.Duration
+	Timestamp       time.Time
+	SequenceNumber  int // -1 if not present
+	SipResponseCode int
+	ErrorCode       int
+	ErrorMessage    string
+	AnsweredBy      string
+	CallbackSource  string
+
+	// MachineDetectionDuration is set when AnsweredBy is present.
+	MachineDetectionDuration time.Duration
+}
+
+// ParseStatusCallback parses the form payload of a Twilio status callback.
+func ParseStatusCallback(form url.Values) (*StatusCallback, error) {
+	cb := &StatusCallback{
+		CallSID:        form.Get("CallSid"),
+		AccountSID:     form.Get("AccountSid"),
+		ParentCallSID:  form.Get("ParentCallSid"),
+		CallStatus:     form.Get("CallStatus"),
+		Direction:      form.Get("Direction"),
+		From:           form.Get("From"),
+		To:             form.Get("To"),
+		ErrorMessage:   form.Get("ErrorMessage"),
+		AnsweredBy:     form.Get("AnsweredBy"),
+		CallbackSource: form.Get("CallbackSource"),
+		SequenceNumber: -1,
+	}
+
+	if cb.CallSID == "" {
+		return nil, fmt.Errorf("status callback missing CallSid")
+	}
+	if cb.CallStatus == "" {
+		return nil, fmt.Errorf("status callback missing CallStatus")
+	}
+
+	var err error
+	if v := form.Get("CallDuration"); v != "" {
+		var secs int
+		if secs, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid CallDuration %q: %w", v, err)
+		}
+		cb.CallDuration = time.Duration(secs) * time.Second
+	}
+	if v := form.Get("Timestamp"); v != "" {
+		if cb.Timestamp, err = parseTwilioTime(v); err != nil {
+			return nil, fmt.Errorf("invalid Timestamp %q: %w", v, err)
+		}
+	}
+	if v := form.Get("SequenceNumber"); v != "" {
+		if cb.SequenceNumber, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid SequenceNumber %q: %w", v, err)
+		}
+	}
+	if v := form.Get("SipResponseCode"); v != "" {
+		if cb.SipResponseCode, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid SipResponseCode %q: %w", v, err)
+		}
+	}
+	if v := form.Get("ErrorCode"); v != "" {
+		if cb.ErrorCode, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid ErrorCode %q: %w", v, err)
+		}
+	}
+	if v := form.Get("MachineDetectionDuration"); v != "" {
+		if ms, err := strconv.Atoi(v); err == nil {
+			cb.MachineDetectionDuration = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	return cb, nil
+}
+
+// StatusTransition records when a call entered a Twilio status.
+type StatusTransition struct {
+	// Status is the raw Twilio status (e.g. "ringing").
+	Status string
+
+	// Time is when Twilio reported the transition.
+	Time time.Time
+
+	// SequenceNumber is the callback sequence number, or -1 if unknown.
+	SequenceNumber int
+}
+
+// Transitions returns the call's status transitions in the order they
+// were applied.
+func (c *Call) Transitions() []StatusTransition {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	transitions := make([]StatusTransition, len(c.transitions))
+	copy(transitions, c.transitions)
+	return transitions
+}
+
+// StatusTime returns when the call entered the given Twilio status.
+func (c *Call) StatusTime(status string) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, t := range c.transitions {
+		if t.Status == status {
+			return t.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// HandleStatusCallbackForm parses and processes a Twilio status callback
+// webhook. Pass the parsed request form.
+func (p *Provider) HandleStatusCallbackForm(form url.Values) error {
+	cb, err := ParseStatusCallback(form)
+	if err != nil {
+		return err
+	}
+	p.handleStatus(cb)
+	return nil
+}
+
+// handleStatus applies a status callback to its call and publishes the
+// matching lifecycle event. Callbacks older than one already applied
+// (by SequenceNumber) and callbacks after the call ended are ignored.
+func (p *Provider) handleStatus(cb *StatusCallback) {
+	ctx := context.Background()
+
+	var call *Call
+	var applied, endedNow bool
+	err := p.store.Update(ctx, cb.CallSID, func(record *CallRecord) error {
+		call = p.callFromRecord(record)
+		applied, endedNow = call.applyStatus(cb)
+		*record = *call.record()
+		return nil
+	})
+	if err != nil || !applied {
+		return
+	}
+
+	if isTerminalStatus(cb.CallStatus) {
+		p.forget(ctx, cb.CallSID)
+	}
+
+	if cb.AnsweredBy != "" {
+		p.applyMachineDetection(call, cb.AnsweredBy, cb.MachineDetectionDuration)
+	}
+
+	if eventType, ok := lifecycleEvent(cb.CallStatus); ok {
+		p.events.publish(Event{
+			Type:   eventType,
+			CallID: cb.CallSID,
+			Call:   call,
+			Time:   cb.Timestamp,
+			Status: cb,
+		})
+	}
+
+	if endedNow {
+		call.teardownAgent(ctx)
+		p.events.publish(Event{
+			Type:   EventCallEnded,
+			CallID: cb.CallSID,
+			Call:   call,
+			Time:   cb.Timestamp,
+			Status: cb,
+			Data:   call.EndInfo(),
+		})
+	}
+}
+
+// applyStatus updates the call from a status callback. It reports whether
+// the callback was applied and whether it ended the call. A final status
+// is still recorded for a call already ended locally (e.g. by Hangup).
+func (c *Call) applyStatus(cb *StatusCallback) (applied, endedNow bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cb.SequenceNumber >= 0 {
+		if c.hasSequence && cb.SequenceNumber <= c.lastSequence {
+			return false, false
+		}
+		c.lastSequence = cb.SequenceNumber
+		c.hasSequence = true
+	}
+
+	terminal := isTerminalStatus(cb.CallStatus)
+	if c.ended && (!terminal || c.finalStatus != "") {
+		return false, false
+	}
+
+	at := cb.Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	endedNow = terminal && !c.ended
+	c.status = mapCallStatus(cb.CallStatus)
+	c.markTimeLocked(cb.CallStatus, at)
+	if cb.CallDuration > 0 {
+		c.billableDuration = cb.CallDuration
+	}
+	if terminal {
+		c.ended = true
+		c.finalStatus = cb.CallStatus
+		c.endFromStatusLocked(cb)
+	}
+	c.transitions = append(c.transitions, StatusTransition{
+		Status:         cb.CallStatus,
+		Time:           at,
+		SequenceNumber: cb.SequenceNumber,
+	})
+	return true, endedNow
+}
+
+// lifecycleEvent maps a Twilio status to its lifecycle event.
+func lifecycleEvent(status string) (EventType, bool) {
+	switch status {
+	case "queued", "initiated":
+		return EventCallInitiated, true
+	case "ringing":
+		return EventCallRinging, true
+	case "in-progress":
+		return EventCallAnswered, true
+	case "completed":
+		return EventCallCompleted, true
+	case "busy", "no-answer", "failed", "canceled":
+		return EventCallFailed, true
+	default:
+		return "", false
+	}
+}
+
+// isTerminalStatus reports whether a Twilio status ends the call.
+func isTerminalStatus(status string) bool {
+	switch status {
+	case "completed", "busy", "no-answer", "failed", "canceled":
+		return true
+	default:
+		return false
+	}
+}
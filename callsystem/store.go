@@ -0,0 +1,323 @@
+package callsystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/logging"
+	"github.com/agentplexus/omnivoice/callsystem"
+)
+
+// ErrCallNotFound is returned by a CallStore when a call does not exist.
+var ErrCallNotFound = errors.New("call not found")
+
+// CallRecord is the persisted state of a call. It is shared between
+// provider instances through a CallStore.
+type CallRecord struct {
+	ID           string                   `json:"id"`
+	Direction    callsystem.CallDirection `json:"direction"`
+	Status       callsystem.CallStatus    `json:"status"`
+	From         string                   `json:"from"`
+	To           string                   `json:"to"`
+	CreatedTime  time.Time                `json:"created_time"`
+	StartTime    time.Time                `json:"start_time"`
+	RingTime     time.Time                `json:"ring_time,omitempty"`
+	AnswerTime   time.Time                `json:"answer_time,omitempty"`
+	EndTime      time.Time                `json:"end_time,omitempty"`
+	BillableSecs int                      `json:"billable_secs,omitempty"`
+	Transitions  []StatusTransition       `json:"transitions,omitempty"`
+	LastSequence int                      `json:"last_sequence"` // -1 if none applied
+	Ended        bool                     `json:"ended"`
+	FinalStatus  string                   `json:"final_status,omitempty"`
+	AnsweredBy   AnsweredBy               `json:"answered_by,omitempty"`
+	End          *EndInfo                 `json:"end,omitempty"`
+	PendingEnd   *EndInfo                 `json:"pending_end,omitempty"`
+	SIP          *SIPInfo                 `json:"sip,omitempty"`
+	Trace        map[string]string        `json:"trace,omitempty"` // trace context of the call's span
+	UpdatedAt    time.Time                `json:"updated_at"`
+}
+
+// clone returns a deep copy of the record.
+func (r *CallRecord) clone() *CallRecord {
+	c := *r
+	c.Transitions = append([]StatusTransition(nil), r.Transitions...)
+	c.Trace = maps.Clone(r.Trace)
+	return &c
+}
+
+// CallStore persists call state. Implementations must be safe for
+// concurrent use; stores shared between processes make call state visible
+// to every provider instance behind a load balancer.
+type CallStore interface {
+	// Get returns the call record, or ErrCallNotFound.
+	Get(ctx context.Context, id string) (*CallRecord, error)
+
+	// Put creates or replaces a call record.
+	Put(ctx context.Context, record *CallRecord) error
+
+	// Delete removes a call record. Deleting a missing call is not an error.
+	Delete(ctx context.Context, id string) error
+
+	// List returns all call records.
+	List(ctx context.Context) ([]*CallRecord, error)
+
+	// Update atomically applies fn to a call record and stores the result.
+	// It returns ErrCallNotFound if the call does not exist, or fn's error,
+	// in which case the record is left unchanged.
+	Update(ctx context.Context, id string, fn func(record *CallRecord) error) error
+
+	// CompareAndSwapStatus sets the call status to new if it is currently
+	// old, reporting whether the swap happened.
+	CompareAndSwapStatus(ctx context.Context, id string, old, new callsystem.CallStatus) (bool, error)
+}
+
+// WithCallStore sets the store used to share call state. Defaults to an
+// in-memory store local to the provider.
+func WithCallStore(store CallStore) Option {
+	return func(o *options) {
+		o.store = store
+	}
+}
+
+// Verify interface compliance at compile time.
+var _ CallStore = (*MemoryStore)(nil)
+
+// MemoryStore is a process-local CallStore.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	calls map[string]*CallRecord
+}
+
+// NewMemoryStore creates an empty in-memory call store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{calls: make(map[string]*CallRecord)}
+}
+
+// Get returns the call record, or ErrCallNotFound.
+func (s *MemoryStore) Get(ctx context.Context, id string) (*CallRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.calls[id]
+	if !ok {
+		return nil, ErrCallNotFound
+	}
+	return record.clone(), nil
+}
+
+// Put creates or replaces a call record.
+func (s *MemoryStore) Put(ctx context.Context, record *CallRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[record.ID] = record.clone()
+	return nil
+}
+
+// Delete removes a call record.
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.calls, id)
+	return nil
+}
+
+// List returns all call records.
+func (s *MemoryStore) List(ctx context.Context) ([]*CallRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	records := make([]*CallRecord, 0, len(s.calls))
+	for _, record := range s.calls {
+		records = append(records, record.clone())
+	}
+	return records, nil
+}
+
+// Update atomically applies fn to a call record.
+func (s *MemoryStore) Update(ctx context.Context, id string, fn func(record *CallRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.calls[id]
+	if !ok {
+		return ErrCallNotFound
+	}
+
+	updated := record.clone()
+	if err := fn(updated); err != nil {
+		return err
+	}
+	updated.ID = id
+	s.calls[id] = updated
+	return nil
+}
+
+// CompareAndSwapStatus sets the call status to new if it is currently old.
+func (s *MemoryStore) CompareAndSwapStatus(ctx context.Context, id string, old, new callsystem.CallStatus) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.calls[id]
+	if !ok {
+		return false, ErrCallNotFound
+	}
+	if record.Status != old {
+		return false, nil
+	}
+
+	updated := record.clone()
+	updated.Status = new
+	updated.UpdatedAt = time.Now()
+	s.calls[id] = updated
+	return true, nil
+}
+
+// record returns a snapshot of the call's persisted state.
+func (c *Call) record() *CallRecord {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	record := &CallRecord{
+		ID:           c.id,
+		Direction:    c.direction,
+		Status:       c.status,
+		From:         c.from,
+		To:           c.to,
+		CreatedTime:  c.createdTime,
+		StartTime:    c.startTime,
+		RingTime:     c.ringTime,
+		AnswerTime:   c.answerTime,
+		EndTime:      c.endTime,
+		BillableSecs: int(c.billableDuration / time.Second),
+		End:          copyEndInfo(c.endInfo),
+		PendingEnd:   copyEndInfo(c.pendingEnd),
+		SIP:          c.sip,
+		Trace:        maps.Clone(c.trace),
+		Transitions:  append([]StatusTransition(nil), c.transitions...),
+		LastSequence: -1,
+		Ended:        c.ended,
+		FinalStatus:  c.finalStatus,
+		UpdatedAt:    time.Now(),
+	}
+	if c.hasSequence {
+		record.LastSequence = c.lastSequence
+	}
+	if c.amd != nil {
+		record.AnsweredBy = c.amd.AnsweredBy
+	}
+	return record
+}
+
+// restore replaces the call's persisted state with record, which may have
+// been updated by another provider instance.
+func (c *Call) restore(record *CallRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.status = record.Status
+	c.transitions = append([]StatusTransition(nil), record.Transitions...)
+	c.lastSequence = record.LastSequence
+	c.hasSequence = record.LastSequence >= 0
+	c.ended = record.Ended
+	c.finalStatus = record.FinalStatus
+	c.createdTime = record.CreatedTime
+	c.ringTime = record.RingTime
+	c.answerTime = record.AnswerTime
+	c.endTime = record.EndTime
+	c.billableDuration = time.Duration(record.BillableSecs) * time.Second
+	c.endInfo = copyEndInfo(record.End)
+	c.pendingEnd = copyEndInfo(record.PendingEnd)
+	if record.SIP != nil {
+		c.sip = record.SIP
+	}
+	if c.trace == nil {
+		c.trace = maps.Clone(record.Trace)
+	}
+	if c.amd == nil && record.AnsweredBy != "" {
+		c.amd = &MachineDetectionResult{AnsweredBy: record.AnsweredBy}
+	}
+}
+
+// callFromRecord returns the live call for record if this instance owns
+// it, or a call rebuilt from the record otherwise.
+func (p *Provider) callFromRecord(record *CallRecord) *Call {
+	p.mu.RLock()
+	call, ok := p.calls[record.ID]
+	p.mu.RUnlock()
+
+	if !ok {
+		call = &Call{
+			id:        record.ID,
+			direction: record.Direction,
+			from:      record.From,
+			to:        record.To,
+			startTime: record.StartTime,
+			provider:  p,
+		}
+	}
+	call.restore(record)
+	return call
+}
+
+// lookupCall finds a call owned by this instance or, failing that, in the
+// call store.
+func (p *Provider) lookupCall(ctx context.Context, id string) (*Call, error) {
+	p.mu.RLock()
+	call, ok := p.calls[id]
+	p.mu.RUnlock()
+	if ok {
+		return call, nil
+	}
+
+	record, err := p.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return p.callFromRecord(record), nil
+}
+
+// track registers a call created by this instance, persists it and
+// publishes EventCallCreated, binding any Media Stream that started before
+// the call was known.
+func (p *Provider) track(ctx context.Context, call *Call) error {
+	p.mu.Lock()
+	p.calls[call.id] = call
+	conn, ok := p.pendingStreams[call.id]
+	delete(p.pendingStreams, call.id)
+	p.mu.Unlock()
+
+	err := p.store.Put(ctx, call.record())
+	if err != nil {
+		p.logger.Warn("failed to store call", slog.String(logging.KeyCallSID, call.id), logging.Error(err))
+	}
+
+	p.publish(EventCallCreated, call, nil)
+	call.startWatchdog()
+
+	if ok {
+		p.attachStream(call, conn)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to store call %s: %w", call.id, err)
+	}
+	return nil
+}
+
+// forget removes a call from this instance and the call store.
+func (p *Provider) forget(ctx context.Context, id string) {
+	p.mu.Lock()
+	delete(p.calls, id)
+	p.mu.Unlock()
+
+	if err := p.store.Delete(ctx, id); err != nil {
+		p.logger.Warn("failed to delete call from store", slog.String(logging.KeyCallSID, id), logging.Error(err))
+	}
+}
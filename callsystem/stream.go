@@ -0,0 +1,81 @@
+package callsystem
+
+import (
+	"context"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/transport"
+	omnitransport "github.com/agentplexus/omnivoice/transport"
+)
+
+const (
+	// earlyAudioFrames is how much inbound audio a stream holds until its
+	// call is bound (500 frames of 20 ms = 10 seconds).
+	earlyAudioFrames = 500
+
+	// pendingStreamTimeout bounds how long a stream waits for its call to
+	// be created before it is closed.
+	pendingStreamTimeout = 30 * time.Second
+)
+
+// bindStream binds a new Media Stream to its call. Streams often start
+// before MakeCall returns, so a stream whose call is not yet known is
+// parked (holding its audio) until the call is tracked.
+func (p *Provider) bindStream(conn *transport.Connection) {
+	callSID := conn.CallSID()
+
+	if call, err := p.lookupCall(context.Background(), callSID); err == nil {
+		p.attachStream(call, conn)
+		return
+	}
+
+	p.mu.Lock()
+	if call, ok := p.calls[callSID]; ok {
+		p.mu.Unlock()
+		p.attachStream(call, conn)
+		return
+	}
+	p.pendingStreams[callSID] = conn
+	p.mu.Unlock()
+
+	conn.OnEvent(func(event omnitransport.Event) {
+		if event.Type == omnitransport.EventDisconnected {
+			p.unparkStream(callSID, conn)
+		}
+	})
+	time.AfterFunc(pendingStreamTimeout, func() {
+		if p.unparkStream(callSID, conn) {
+			_ = conn.Close()
+		}
+	})
+}
+
+// unparkStream removes a parked stream, reporting whether it was parked.
+func (p *Provider) unparkStream(callSID string, conn *transport.Connection) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pendingStreams[callSID] != conn {
+		return false
+	}
+	delete(p.pendingStreams, callSID)
+	return true
+}
+
+// attachStream binds conn to call and publishes EventStreamAttached. A
+// call owned by another provider instance is adopted by this one, since
+// this instance now holds its media.
+func (p *Provider) attachStream(call *Call, conn *transport.Connection) {
+	p.mu.Lock()
+	if existing, ok := p.calls[call.id]; ok {
+		call = existing
+	} else {
+		p.calls[call.id] = call
+	}
+	p.mu.Unlock()
+
+	call.SetTransport(conn)
+	conn.ReleaseAudio()
+	call.startWatchdog()
+	go call.applyTimeLimit()
+}
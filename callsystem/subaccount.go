@@ -0,0 +1,93 @@
+package callsystem
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/internal/client"
+)
+
+// Subaccount is a Twilio subaccount of the provider's account.
+type Subaccount struct {
+	SID          string
+	FriendlyName string
+	Status       string // "active", "suspended" or "closed"
+	CreatedTime  time.Time
+}
+
+// subaccountFromAPI converts an API account.
+func subaccountFromAPI(account *client.Account) Subaccount {
+	return Subaccount{
+		SID:          account.SID,
+		FriendlyName: account.FriendlyName,
+		Status:       account.Status,
+		CreatedTime:  parseOptionalTime(account.DateCreated),
+	}
+}
+
+// CreateSubaccount creates a subaccount, e.g. one per customer.
+func (p *Provider) CreateSubaccount(ctx context.Context, friendlyName string) (*Subaccount, error) {
+	account, err := p.client.CreateSubaccount(ctx, friendlyName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subaccount: %w", err)
+	}
+	subaccount := subaccountFromAPI(account)
+	return &subaccount, nil
+}
+
+// ListSubaccounts returns the subaccounts of the provider's account.
+func (p *Provider) ListSubaccounts(ctx context.Context) ([]Subaccount, error) {
+	accounts, err := p.client.ListSubaccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subaccounts: %w", err)
+	}
+
+	subaccounts := make([]Subaccount, 0, len(accounts))
+	for i := range accounts {
+		subaccounts = append(subaccounts, subaccountFromAPI(&accounts[i]))
+	}
+	return subaccounts, nil
+}
+
+// ForSubaccount returns a provider scoped to a subaccount: calls, numbers
+// and lookups use the subaccount's resources and are billed to it.
+//
+// The new provider inherits this provider's configuration and call store,
+// except for the default phone number and explicit caller ID pool numbers,
+// which belong to the parent account; set them with opts. API key
+// credentials are reused as is; with auth token authentication, the
+// subaccount's own auth token is fetched.
+func (p *Provider) ForSubaccount(ctx context.Context, subaccountSID string, opts ...Option) (*Provider, error) {
+	cfg := p.opts
+	cfg.accountSID = subaccountSID
+	cfg.phoneNumber = ""
+	cfg.store = p.store
+
+	if cfg.callerIDs != nil {
+		pool := *cfg.callerIDs
+		pool.Numbers = nil
+		cfg.callerIDs = &pool
+	}
+
+	creds, err := p.client.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if creds.APIKeySID == "" {
+		account, err := p.client.GetAccount(ctx, subaccountSID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get subaccount: %w", err)
+		}
+		if account.OwnerAccountSID != p.client.AccountSID() {
+			return nil, fmt.Errorf("account %s is not a subaccount of %s", subaccountSID, p.client.AccountSID())
+		}
+		cfg.credentials = nil
+		cfg.authToken = account.AuthToken
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return newProvider(&cfg)
+}
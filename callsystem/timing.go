@@ -0,0 +1,179 @@
+package callsystem
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/internal/client"
+)
+
+// parseTwilioTime parses the RFC 2822 timestamps used by Twilio.
+func parseTwilioTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC1123Z, s)
+	if err != nil {
+		return time.Parse(time.RFC1123, s)
+	}
+	return t, nil
+}
+
+// parseOptionalTime parses a Twilio timestamp, returning the zero time for
+// empty or malformed values.
+func parseOptionalTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, _ := parseTwilioTime(s)
+	return t
+}
+
+// Billing is the billed duration and price of a call as reported by Twilio.
+type Billing struct {
+	// Duration is the billable duration, rounded up to whole seconds by
+	// Twilio.
+	Duration time.Duration
+
+	// Price is the call price as reported by Twilio (charges are
+	// negative). It is zero until Twilio has priced the call, which can
+	// happen some time after it ends.
+	Price float64
+
+	// PriceUnit is the ISO 4217 currency of Price (e.g. "USD").
+	PriceUnit string
+
+	// Priced reports whether Twilio has priced the call yet.
+	Priced bool
+}
+
+// parseBilling extracts billing details from a call resource.
+func parseBilling(tc *client.Call) (*Billing, error) {
+	billing := &Billing{PriceUnit: tc.PriceUnit}
+
+	if tc.Duration != "" {
+		secs, err := strconv.Atoi(tc.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid call duration %q: %w", tc.Duration, err)
+		}
+		billing.Duration = time.Duration(secs) * time.Second
+	}
+	if tc.Price != "" {
+		price, err := strconv.ParseFloat(tc.Price, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid call price %q: %w", tc.Price, err)
+		}
+		billing.Price = price
+		billing.Priced = true
+	}
+	return billing, nil
+}
+
+// RingTime returns when the call started ringing, or the zero time.
+func (c *Call) RingTime() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ringTime
+}
+
+// AnswerTime returns when the call was answered, or the zero time.
+func (c *Call) AnswerTime() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.answerTime
+}
+
+// EndTime returns when the call ended, or the zero time.
+func (c *Call) EndTime() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.endTime
+}
+
+// TalkDuration returns how long the call has been connected, from answer
+// to end (or now). It is zero for calls that were never answered.
+func (c *Call) TalkDuration() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.answerTime.IsZero() {
+		return 0
+	}
+	if !c.endTime.IsZero() {
+		return c.endTime.Sub(c.answerTime)
+	}
+	return time.Since(c.answerTime)
+}
+
+// Billing fetches the call's billable duration and price from Twilio.
+func (c *Call) Billing(ctx context.Context) (*Billing, error) {
+	tc, err := c.provider.client.GetCall(c.TraceContext(ctx), c.id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+
+	billing, err := parseBilling(tc)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.billing = billing
+	c.mu.Unlock()
+
+	return billing, nil
+}
+
+// markTimeLocked records the time a call entered a Twilio status. Times
+// already set are kept. It must be called with c.mu held.
+func (c *Call) markTimeLocked(status string, at time.Time) {
+	switch {
+	case status == "ringing":
+		if c.ringTime.IsZero() {
+			c.ringTime = at
+		}
+	case status == "in-progress":
+		if c.answerTime.IsZero() {
+			c.answerTime = at
+		}
+	case isTerminalStatus(status):
+		if c.endTime.IsZero() {
+			c.endTime = at
+		}
+	}
+}
+
+// callFromAPI builds a call from a Twilio call resource.
+func (p *Provider) callFromAPI(tc *client.Call) *Call {
+	call := &Call{
+		id:          tc.SID,
+		direction:   mapDirection(tc.Direction),
+		status:      mapCallStatus(tc.Status),
+		from:        tc.From,
+		to:          tc.To,
+		createdTime: parseOptionalTime(tc.DateCreated),
+		startTime:   parseOptionalTime(tc.StartTime),
+		provider:    p,
+	}
+	if call.startTime.IsZero() {
+		call.startTime = call.createdTime
+	}
+
+	if isTerminalStatus(tc.Status) {
+		call.ended = true
+		call.finalStatus = tc.Status
+		call.endTime = parseOptionalTime(tc.EndTime)
+	}
+
+	if billing, err := parseBilling(tc); err == nil {
+		call.billing = billing
+		// Twilio reports the talk time of completed calls as their duration
+		if tc.Status == "completed" && !call.endTime.IsZero() {
+			call.answerTime = call.endTime.Add(-billing.Duration)
+		}
+	}
+	if tc.AnsweredBy != "" {
+		call.amd = &MachineDetectionResult{AnsweredBy: AnsweredBy(tc.AnsweredBy)}
+	}
+
+	return call
+}
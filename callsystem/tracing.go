@@ -0,0 +1,79 @@
+package callsystem
+
+import (
+	"context"
+
+	"github.com/agentplexus/omnivoice-twilio/tracing"
+	"github.com/agentplexus/omnivoice/callsystem"
+)
+
+// WithTracer traces each call with a span keyed by its CallSid, with child
+// spans for REST requests, Media Streams and the agent pipeline. See
+// package tracing.
+func WithTracer(tracer tracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// startCallSpan starts a call's span as a child of the span in ctx, if any.
+func (p *Provider) startCallSpan(ctx context.Context, direction callsystem.CallDirection) (context.Context, tracing.Span) {
+	ctx, span := p.tracer.Start(ctx, tracing.SpanCall)
+	span.SetAttribute(tracing.AttrDirection, string(direction))
+	span.SetAttribute(tracing.AttrAccountSID, p.client.AccountSID())
+	return ctx, span
+}
+
+// TraceContext returns ctx carrying the call's span, so spans started from
+// it are children of the call. Calls owned by another provider instance
+// carry the span's context through the call store.
+func (c *Call) TraceContext(ctx context.Context) context.Context {
+	c.mu.RLock()
+	trace := c.trace
+	c.mu.RUnlock()
+	return c.provider.tracer.Extract(ctx, trace)
+}
+
+// endSpan ends the call's span, if this instance started it.
+func (c *Call) endSpan(info *EndInfo) {
+	c.mu.Lock()
+	span := c.span
+	c.span = nil
+	c.mu.Unlock()
+
+	if span == nil {
+		return
+	}
+	reason := EndReasonUnknown
+	if info != nil {
+		reason = info.Reason
+		if info.ErrorCode != 0 {
+			span.SetAttribute(tracing.AttrErrorCode, info.ErrorCode)
+		}
+	}
+	span.SetAttribute(tracing.AttrEndReason, string(reason))
+	span.End()
+}
+
+// trace records lifecycle events on the call's span and ends it when the
+// call ends.
+func (b *eventBus) trace(event Event) {
+	call := event.Call
+	if call == nil {
+		return
+	}
+
+	switch event.Type {
+	case EventCallEnded:
+		info, _ := event.Data.(*EndInfo)
+		call.endSpan(info)
+	case EventCallRinging, EventCallAnswered, EventStreamAttached, EventStreamDetached,
+		EventAgentAttached, EventAgentDetached:
+		call.mu.RLock()
+		span := call.span
+		call.mu.RUnlock()
+		if span != nil {
+			span.AddEvent(string(event.Type))
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package callsystem
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/agentplexus/omnivoice-twilio/credentials"
+)
+
+// WithSignatureValidation makes the Media Streams transport reject
+// WebSocket requests without a valid X-Twilio-Signature. Validate other
+// webhooks with ValidateWebhook.
+func WithSignatureValidation() Option {
+	return func(o *options) {
+		o.validateSignatures = true
+	}
+}
+
+// ValidateWebhook checks that a webhook request was signed by Twilio with
+// the primary or secondary auth token. publicURL is the URL Twilio was
+// configured to call, including any query string; if empty it is derived
+// from the request, which is only correct when no proxy rewrites the host
+// or scheme. It parses the request form, which remains available in
+// r.PostForm. A mismatch returns an error matching
+// credentials.ErrInvalidSignature.
+func (p *Provider) ValidateWebhook(r *http.Request, publicURL string) error {
+	if publicURL == "" {
+		scheme := "https"
+		if r.TLS == nil {
+			scheme = "http"
+		}
+		publicURL = scheme + "://" + r.Host + r.URL.RequestURI()
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("failed to parse webhook: %w", err)
+	}
+
+	signature := r.Header.Get("X-Twilio-Signature")
+	if err := credentials.ValidateSignature(r.Context(), p.client.CredentialsProvider(), signature, publicURL, r.PostForm); err != nil {
+		return fmt.Errorf("failed to validate webhook: %w", err)
+	}
+	return nil
+}
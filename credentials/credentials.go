@@ -0,0 +1,289 @@
+// Package credentials supplies Twilio credentials to the REST client and
+// webhook signature validation, so tokens can be rotated without
+// recreating providers.
+package credentials
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is how often a FileProvider checks its file for
+// changes unless WithReloadInterval is set.
+const DefaultReloadInterval = 10 * time.Second
+
+// ErrInvalidSignature is returned for webhook requests whose
+// X-Twilio-Signature matches none of the auth tokens.
+var ErrInvalidSignature = errors.New("invalid Twilio signature")
+
+// Credentials are the secrets used to talk to Twilio.
+type Credentials struct {
+	AccountSID string `json:"account_sid"`
+
+	// AuthToken is the primary auth token.
+	AuthToken string `json:"auth_token"`
+
+	// SecondaryAuthToken is accepted for webhook signatures alongside
+	// AuthToken while a token rotation is in progress.
+	SecondaryAuthToken string `json:"secondary_auth_token,omitempty"`
+
+	// APIKeySID and APIKeySecret, if set, authenticate REST requests
+	// instead of AuthToken. Webhook signatures are always made with the
+	// auth token.
+	APIKeySID    string `json:"api_key_sid,omitempty"`
+	APIKeySecret string `json:"api_key_secret,omitempty"`
+}
+
+// BasicAuth returns the username and password for REST requests.
+func (c Credentials) BasicAuth() (username, password string) {
+	if c.APIKeySID != "" {
+		return c.APIKeySID, c.APIKeySecret
+	}
+	return c.AccountSID, c.AuthToken
+}
+
+// Validate checks that the credentials can authenticate REST requests.
+func (c Credentials) Validate() error {
+	switch {
+	case c.APIKeySID != "" && c.APIKeySecret == "":
+		return fmt.Errorf("API key secret is required with API key %s", c.APIKeySID)
+	case c.APIKeySID == "" && c.AuthToken == "":
+		return fmt.Errorf("an auth token or API key is required")
+	}
+	return nil
+}
+
+// Provider supplies credentials. It is consulted for every REST request
+// and webhook, so implementations must be cheap and safe for concurrent
+// use.
+type Provider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface.
+type ProviderFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials calls f.
+func (f ProviderFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// Static returns a Provider that always returns creds.
+func Static(creds Credentials) Provider {
+	return ProviderFunc(func(context.Context) (Credentials, error) {
+		return creds, nil
+	})
+}
+
+// Env returns a Provider that reads TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN,
+// TWILIO_SECONDARY_AUTH_TOKEN, TWILIO_API_KEY and TWILIO_API_SECRET on every
+// call.
+func Env() Provider {
+	return ProviderFunc(func(context.Context) (Credentials, error) {
+		return Credentials{
+			AccountSID:         os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:          os.Getenv("TWILIO_AUTH_TOKEN"),
+			SecondaryAuthToken: os.Getenv("TWILIO_SECONDARY_AUTH_TOKEN"),
+			APIKeySID:          os.Getenv("TWILIO_API_KEY"),
+			APIKeySecret:       os.Getenv("TWILIO_API_SECRET"),
+		}, nil
+	})
+}
+
+// FileProvider reads credentials from a JSON file, such as a mounted
+// Kubernetes secret, and reloads it when it changes. The file holds a JSON
+// object with the Credentials field names, e.g.
+//
+//	{"account_sid": "AC...", "auth_token": "...", "secondary_auth_token": "..."}
+type FileProvider struct {
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	creds   Credentials
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+// FileOption configures a FileProvider.
+type FileOption func(*FileProvider)
+
+// WithReloadInterval sets how often the file is checked for changes.
+// Defaults to DefaultReloadInterval.
+func WithReloadInterval(interval time.Duration) FileOption {
+	return func(p *FileProvider) {
+		p.interval = interval
+	}
+}
+
+// NewFileProvider creates a FileProvider and loads the file.
+func NewFileProvider(path string, opts ...FileOption) (*FileProvider, error) {
+	p := &FileProvider{path: path, interval: DefaultReloadInterval}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	if err := p.load(info); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Credentials returns the current credentials, reloading the file if it
+// changed since it was last checked. If a reload fails the previous
+// credentials are kept.
+func (p *FileProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.checked) >= p.interval {
+		p.checked = time.Now()
+		if info, err := os.Stat(p.path); err == nil && (!info.ModTime().Equal(p.modTime) || info.Size() != p.size) {
+			// A half-written file is retried on the next check
+			_ = p.load(info)
+		}
+	}
+	return p.creds, nil
+}
+
+// Reload reads the file now.
+func (p *FileProvider) Reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.load(info)
+}
+
+// load reads the file. It must be called with p.mu held, except from
+// NewFileProvider.
+func (p *FileProvider) load(info os.FileInfo) error {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	if err := creds.Validate(); err != nil {
+		return fmt.Errorf("invalid credentials in %s: %w", p.path, err)
+	}
+
+	p.creds = creds
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+	p.checked = time.Now()
+	return nil
+}
+
+// ValidateSignature checks the X-Twilio-Signature of a webhook request
+// against the primary and secondary auth tokens. rawURL is the full public
+// URL Twilio requested, including any query string, and params the POST
+// form parameters (nil for GET requests).
+func ValidateSignature(ctx context.Context, provider Provider, signature, rawURL string, params url.Values) error {
+	creds, err := provider.Credentials(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %w", err)
+	}
+	if creds.AuthToken == "" && creds.SecondaryAuthToken == "" {
+		return fmt.Errorf("an auth token is required to validate signatures")
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || signature == "" {
+		return ErrInvalidSignature
+	}
+
+	for _, token := range []string{creds.AuthToken, creds.SecondaryAuthToken} {
+		if token == "" {
+			continue
+		}
+		for _, u := range urlVariants(rawURL) {
+			if hmac.Equal(sign(token, u, params), expected) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Sign returns the X-Twilio-Signature Twilio sends for a request, e.g. to
+// test webhook handlers.
+func Sign(authToken, rawURL string, params url.Values) string {
+	return base64.StdEncoding.EncodeToString(sign(authToken, rawURL, params))
+}
+
+// sign computes the HMAC-SHA1 of the URL followed by the sorted parameters.
+func sign(authToken, rawURL string, params url.Values) []byte {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(rawURL)
+	for _, key := range keys {
+		values := append([]string(nil), params[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			b.WriteString(key)
+			b.WriteString(value)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	return mac.Sum(nil)
+}
+
+// urlVariants returns the URL with and without the scheme's default port,
+// since Twilio may sign either form depending on how the URL was
+// configured.
+func urlVariants(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return []string{rawURL}
+	}
+
+	var port string
+	switch u.Scheme {
+	case "https", "wss":
+		port = "443"
+	case "http", "ws":
+		port = "80"
+	default:
+		return []string{rawURL}
+	}
+
+	alt := *u
+	if u.Port() == "" {
+		alt.Host = u.Host + ":" + port
+	} else if u.Port() == port {
+		alt.Host = u.Hostname()
+	} else {
+		return []string{rawURL}
+	}
+	return []string{rawURL, alt.String()}
+}
@@ -0,0 +1,151 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// Twilio's documented example request (twilio.com/docs/usage/security).
+const (
+	exampleToken     = "12345"
+	exampleURL       = "https://mycompany.com/myapp.php?foo=1&bar=2"
+	exampleSignature = "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+)
+
+var exampleParams = url.Values{
+	"CallSid": {"CA1234567890ABCDE"},
+	"Caller":  {"+12349013030"},
+	"Digits":  {"1234"},
+	"From":    {"+12349013030"},
+	"To":      {"+18005551212"},
+}
+
+func TestSign(t *testing.T) {
+	if got := Sign(exampleToken, exampleURL, exampleParams); got != exampleSignature {
+		t.Errorf("Sign() = %q, want %q", got, exampleSignature)
+	}
+}
+
+func TestValidateSignature(t *testing.T) {
+	ctx := context.Background()
+	withPort := "https://mycompany.com:443/myapp.php?foo=1&bar=2"
+
+	tests := []struct {
+		name      string
+		creds     Credentials
+		signature string
+		url       string
+		params    url.Values
+		wantErr   error
+	}{
+		{
+			name:      "primary token",
+			creds:     Credentials{AuthToken: exampleToken},
+			signature: exampleSignature,
+			url:       exampleURL,
+			params:    exampleParams,
+		},
+		{
+			name:      "secondary token",
+			creds:     Credentials{AuthToken: "rotated", SecondaryAuthToken: exampleToken},
+			signature: exampleSignature,
+			url:       exampleURL,
+			params:    exampleParams,
+		},
+		{
+			name:      "signed without default port",
+			creds:     Credentials{AuthToken: exampleToken},
+			signature: exampleSignature,
+			url:       withPort,
+			params:    exampleParams,
+		},
+		{
+			name:      "signed with default port",
+			creds:     Credentials{AuthToken: exampleToken},
+			signature: Sign(exampleToken, withPort, exampleParams),
+			url:       exampleURL,
+			params:    exampleParams,
+		},
+		{
+			name:      "wrong token",
+			creds:     Credentials{AuthToken: "other"},
+			signature: exampleSignature,
+			url:       exampleURL,
+			params:    exampleParams,
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "tampered parameter",
+			creds:     Credentials{AuthToken: exampleToken},
+			signature: exampleSignature,
+			url:       exampleURL,
+			params:    url.Values{"CallSid": {"CA1234567890ABCDE"}, "Digits": {"9999"}},
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "different URL",
+			creds:     Credentials{AuthToken: exampleToken},
+			signature: exampleSignature,
+			url:       "https://mycompany.com/other.php?foo=1&bar=2",
+			params:    exampleParams,
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "empty signature",
+			creds:     Credentials{AuthToken: exampleToken},
+			signature: "",
+			url:       exampleURL,
+			params:    exampleParams,
+			wantErr:   ErrInvalidSignature,
+		},
+		{
+			name:      "malformed signature",
+			creds:     Credentials{AuthToken: exampleToken},
+			signature: "not base64!",
+			url:       exampleURL,
+			params:    exampleParams,
+			wantErr:   ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSignature(ctx, Static(tt.creds), tt.signature, tt.url, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateSignature() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSignatureNoToken(t *testing.T) {
+	creds := Credentials{AccountSID: "AC123", APIKeySID: "SK123", APIKeySecret: "secret"}
+	err := ValidateSignature(context.Background(), Static(creds), exampleSignature, exampleURL, exampleParams)
+	if err == nil || errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ValidateSignature() error = %v, want missing auth token error", err)
+	}
+}
+
+func TestURLVariants(t *testing.T) {
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://example.com/hook", []string{"https://example.com/hook", "https://example.com:443/hook"}},
+		{"https://example.com:443/hook", []string{"https://example.com:443/hook", "https://example.com/hook"}},
+		{"http://example.com/hook?a=1", []string{"http://example.com/hook?a=1", "http://example.com:80/hook?a=1"}},
+		{"wss://example.com/stream", []string{"wss://example.com/stream", "wss://example.com:443/stream"}},
+		{"https://example.com:8443/hook", []string{"https://example.com:8443/hook"}},
+		{"ftp://example.com/hook", []string{"ftp://example.com/hook"}},
+		{"/relative", []string{"/relative"}},
+	}
+
+	for _, tt := range tests {
+		if got := urlVariants(tt.url); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("urlVariants(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
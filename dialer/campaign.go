@@ -0,0 +1,646 @@
+// Package dialer runs outbound call campaigns on top of the Twilio call
+// system: it paces and limits concurrent calls, respects per-target calling
+// windows, retries busy and unanswered targets, and records outcomes.
+package dialer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/callsystem"
+	"github.com/agentplexus/omnivoice-twilio/logging"
+	omnicallsystem "github.com/agentplexus/omnivoice/callsystem"
+)
+
+// Campaign defaults.
+const (
+	// DefaultMaxAttempts is the number of attempts per target when
+	// RetryPolicy.MaxAttempts is zero.
+	DefaultMaxAttempts = 3
+
+	// DefaultRetryDelay is the retry delay when RetryPolicy.Backoff is
+	// empty.
+	DefaultRetryDelay = 10 * time.Minute
+
+	// DefaultCallTimeout bounds how long an attempt waits for its call to
+	// end when Config.CallTimeout is zero.
+	DefaultCallTimeout = 2 * time.Hour
+)
+
+// ErrCampaignRunning is returned by Run if the campaign is already running.
+var ErrCampaignRunning = errors.New("campaign is already running")
+
+// Target is a number to call.
+type Target struct {
+	// ID identifies the target in outcomes. Defaults to Number.
+	ID string
+
+	// Number is the number to call.
+	Number string
+
+	// Location is the target's time zone for calling windows. Defaults
+	// to Config.Location.
+	Location *time.Location
+
+	// Data is passed through to hooks and outcomes.
+	Data map[string]string
+}
+
+// RetryPolicy controls when targets are called again.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of calls per target, including
+	// the first. Defaults to DefaultMaxAttempts.
+	MaxAttempts int
+
+	// Backoff lists the delays before each retry; the last delay repeats.
+	// Defaults to DefaultRetryDelay.
+	Backoff []time.Duration
+
+	// RetryOn lists the end reasons that are retried. Defaults to
+	// busy and no answer.
+	RetryOn []callsystem.EndReason
+}
+
+// Config configures a campaign.
+type Config struct {
+	// MaxConcurrent limits calls in progress at once. Defaults to 1.
+	MaxConcurrent int
+
+	// CallsPerSecond limits how fast calls are placed. Defaults to 1.
+	CallsPerSecond float64
+
+	// Windows restricts when targets may be called. Empty means any time.
+	Windows []Window
+
+	// Location is the default time zone for Windows. Defaults to UTC.
+	Location *time.Location
+
+	// Retry controls retries.
+	Retry RetryPolicy
+
+	// CallOptions are passed to every MakeCall. Outcomes are only known
+	// once calls end, so status callbacks should be configured (see
+	// omnicallsystem.WithStatusCallback) and passed to the provider.
+	CallOptions []omnicallsystem.CallOption
+
+	// CallTimeout bounds how long an attempt waits for its call to end.
+	// Defaults to DefaultCallTimeout.
+	CallTimeout time.Duration
+
+	// OnDial is called after each call is placed, e.g. to attach an
+	// agent.
+	OnDial func(ctx context.Context, call omnicallsystem.Call, target Target)
+
+	// OnOutcome is called once per target when it reaches a final status.
+	OnOutcome func(outcome Outcome)
+
+	// Logger receives errors that do not fail an attempt. Defaults to
+	// discarding them.
+	Logger *slog.Logger
+}
+
+// withDefaults fills in unset fields.
+func (c Config) withDefaults() Config {
+	if c.MaxConcurrent <= 0 {
+		c.MaxConcurrent = 1
+	}
+	if c.CallsPerSecond <= 0 {
+		c.CallsPerSecond = 1
+	}
+	if c.Location == nil {
+		c.Location = time.UTC
+	}
+	if c.Retry.MaxAttempts <= 0 {
+		c.Retry.MaxAttempts = DefaultMaxAttempts
+	}
+	if len(c.Retry.Backoff) == 0 {
+		c.Retry.Backoff = []time.Duration{DefaultRetryDelay}
+	}
+	if c.Retry.RetryOn == nil {
+		c.Retry.RetryOn = []callsystem.EndReason{callsystem.EndReasonBusy, callsystem.EndReasonNoAnswer}
+	}
+	if c.CallTimeout <= 0 {
+		c.CallTimeout = DefaultCallTimeout
+	}
+	c.Logger = logging.Logger(c.Logger)
+	return c
+}
+
+// Status is the state of a target in a campaign.
+type Status string
+
+// Target statuses.
+const (
+	StatusPending    Status = "pending"     // waiting to be called
+	StatusInProgress Status = "in_progress" // a call is in progress
+	StatusCompleted  Status = "completed"   // the call connected
+	StatusFailed     Status = "failed"      // not connected after all attempts
+	StatusCanceled   Status = "canceled"    // the campaign was canceled first
+)
+
+// final reports whether the status is final.
+func (s Status) final() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCanceled
+}
+
+// Attempt is one call to a target.
+type Attempt struct {
+	CallID    string
+	StartTime time.Time
+	EndTime   time.Time
+	EndReason callsystem.EndReason
+	Error     string // set if the call could not be placed
+}
+
+// Outcome is the result of calling a target.
+type Outcome struct {
+	Target      Target
+	Status      Status
+	Attempts    []Attempt
+	NextAttempt time.Time // when the target is next due, while pending
+}
+
+// Stats counts targets by status.
+type Stats struct {
+	Total      int
+	Pending    int
+	InProgress int
+	Completed  int
+	Failed     int
+	Canceled   int
+}
+
+// targetState tracks a target while the campaign runs.
+type targetState struct {
+	target      Target
+	status      Status
+	attempts    []Attempt
+	nextAttempt time.Time
+	timer       *time.Timer // attempt timeout
+}
+
+// outcome returns a snapshot of the target's outcome.
+func (t *targetState) outcome() Outcome {
+	return Outcome{
+		Target:      t.target,
+		Status:      t.status,
+		Attempts:    append([]Attempt(nil), t.attempts...),
+		NextAttempt: t.nextAttempt,
+	}
+}
+
+// Campaign calls a list of targets. Create one with New and start it with
+// Run.
+type Campaign struct {
+	provider *callsystem.Provider
+	config   Config
+
+	mu       sync.Mutex
+	targets  []*targetState
+	byID     map[string]*targetState
+	calls    map[string]*targetState // in-progress calls by call ID
+	active   int
+	running  bool
+	paused   bool
+	lastDial time.Time
+	wake     chan struct{}
+}
+
+// New creates a campaign that calls targets through provider.
+func New(provider *callsystem.Provider, targets []Target, config Config) (*Campaign, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("provider is required")
+	}
+	for _, w := range config.Windows {
+		if err := w.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &Campaign{
+		provider: provider,
+		config:   config.withDefaults(),
+		byID:     make(map[string]*targetState),
+		calls:    make(map[string]*targetState),
+		wake:     make(chan struct{}, 1),
+	}
+
+	for _, target := range targets {
+		if target.Number == "" {
+			return nil, fmt.Errorf("target %q has no number", target.ID)
+		}
+		if target.ID == "" {
+			target.ID = target.Number
+		}
+		if _, ok := c.byID[target.ID]; ok {
+			return nil, fmt.Errorf("duplicate target %q", target.ID)
+		}
+		if target.Location == nil {
+			target.Location = c.config.Location
+		}
+
+		state := &targetState{target: target, status: StatusPending}
+		c.targets = append(c.targets, state)
+		c.byID[target.ID] = state
+	}
+
+	return c, nil
+}
+
+// Run calls targets until each has a final status, or ctx is done. On
+// cancellation pending targets are marked canceled and Run returns
+// ctx.Err(); calls already in progress are left running.
+func (c *Campaign) Run(ctx context.Context) error {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return ErrCampaignRunning
+	}
+	c.running = true
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.running = false
+		c.mu.Unlock()
+	}()
+
+	sub := c.provider.Subscribe(
+		callsystem.WithEventTypes(callsystem.EventCallEnded),
+		callsystem.WithBufferSize(callsystem.DefaultSubscriberBuffer+c.config.MaxConcurrent),
+	)
+	defer sub.Unsubscribe()
+	go c.watchCalls(sub)
+
+	for {
+		state, wait, done := c.next(time.Now())
+		if done {
+			return nil
+		}
+
+		if state == nil {
+			if err := c.sleep(ctx, wait); err != nil {
+				c.cancelPending()
+				return err
+			}
+			continue
+		}
+
+		if err := c.pace(ctx); err != nil {
+			c.release(state)
+			c.cancelPending()
+			return err
+		}
+		go c.dial(ctx, state)
+	}
+}
+
+// Pause stops placing new calls. Calls in progress continue.
+func (c *Campaign) Pause() {
+	c.mu.Lock()
+	c.paused = true
+	c.mu.Unlock()
+}
+
+// Resume continues placing calls after Pause.
+func (c *Campaign) Resume() {
+	c.mu.Lock()
+	c.paused = false
+	c.mu.Unlock()
+	c.notify()
+}
+
+// Paused reports whether the campaign is paused.
+func (c *Campaign) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// Outcome returns the outcome of a target so far.
+func (c *Campaign) Outcome(targetID string) (Outcome, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state, ok := c.byID[targetID]
+	if !ok {
+		return Outcome{}, false
+	}
+	return state.outcome(), true
+}
+
+// Outcomes returns the outcomes of all targets so far, in target order.
+func (c *Campaign) Outcomes() []Outcome {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	outcomes := make([]Outcome, 0, len(c.targets))
+	for _, state := range c.targets {
+		outcomes = append(outcomes, state.outcome())
+	}
+	return outcomes
+}
+
+// Stats counts targets by status.
+func (c *Campaign) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := Stats{Total: len(c.targets)}
+	for _, state := range c.targets {
+		switch state.status {
+		case StatusPending:
+			stats.Pending++
+		case StatusInProgress:
+			stats.InProgress++
+		case StatusCompleted:
+			stats.Completed++
+		case StatusFailed:
+			stats.Failed++
+		case StatusCanceled:
+			stats.Canceled++
+		}
+	}
+	return stats
+}
+
+// next picks the next target to call and reserves a call slot for it.
+// If none is ready it returns how long to wait (zero to wait for a wake
+// up), and done once every target is final.
+func (c *Campaign) next(now time.Time) (state *targetState, wait time.Duration, done bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var earliest time.Time
+	remaining := false
+	for _, t := range c.targets {
+		if t.status.final() {
+			continue
+		}
+		remaining = true
+		if t.status != StatusPending {
+			continue
+		}
+
+		due := t.nextAttempt
+		if due.Before(now) {
+			due = now
+		}
+		due = nextOpen(due, c.config.Windows, t.target.Location)
+		if due.After(now) {
+			if earliest.IsZero() || due.Before(earliest) {
+				earliest = due
+			}
+			continue
+		}
+
+		if state == nil {
+			state = t
+		}
+	}
+
+	if !remaining {
+		return nil, 0, true
+	}
+	if c.paused || c.active >= c.config.MaxConcurrent {
+		return nil, 0, false
+	}
+	if state == nil {
+		if earliest.IsZero() {
+			return nil, 0, false
+		}
+		return nil, earliest.Sub(now), false
+	}
+
+	state.status = StatusInProgress
+	c.active++
+	return state, 0, false
+}
+
+// release returns a reserved target and its call slot unused.
+func (c *Campaign) release(state *targetState) {
+	c.mu.Lock()
+	state.status = StatusPending
+	c.active--
+	c.mu.Unlock()
+}
+
+// sleep waits for d (or a wake up if d is zero), a wake up, or ctx.
+func (c *Campaign) sleep(ctx context.Context, d time.Duration) error {
+	var timeout <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.wake:
+	case <-timeout:
+	}
+	return nil
+}
+
+// notify wakes Run to reconsider targets.
+func (c *Campaign) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pace waits until the next call may be placed under CallsPerSecond.
+func (c *Campaign) pace(ctx context.Context) error {
+	interval := time.Duration(float64(time.Second) / c.config.CallsPerSecond)
+
+	c.mu.Lock()
+	at := c.lastDial.Add(interval)
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	c.lastDial = at
+	c.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// dial places a call to a reserved target. A call that was placed but
+// could not be stored is still tracked, so it is not retried while live.
+func (c *Campaign) dial(ctx context.Context, state *targetState) {
+	attempt := Attempt{StartTime: time.Now()}
+
+	call, err := c.provider.MakeCall(ctx, state.target.Number, c.config.CallOptions...)
+	if call != nil && err != nil {
+		c.config.Logger.WarnContext(ctx, "campaign call placed but not stored",
+			slog.String(logging.KeyCallSID, call.ID()), logging.Error(err))
+	} else if err != nil {
+		if ctx.Err() != nil {
+			c.cancelAttempt(state)
+			return
+		}
+		attempt.Error = err.Error()
+		c.finish(state, attempt, callsystem.EndReasonFailed)
+		return
+	}
+	attempt.CallID = call.ID()
+
+	c.mu.Lock()
+	state.attempts = append(state.attempts, attempt)
+	c.calls[attempt.CallID] = state
+	state.timer = time.AfterFunc(c.config.CallTimeout, func() {
+		c.callEnded(attempt.CallID, callsystem.EndReasonUnknown)
+	})
+	c.mu.Unlock()
+
+	// The call may have ended before it was registered
+	if tc, ok := call.(*callsystem.Call); ok {
+		if reason := tc.EndReason(); reason != "" {
+			c.callEnded(attempt.CallID, reason)
+			return
+		}
+	}
+
+	if c.config.OnDial != nil {
+		c.config.OnDial(ctx, call, state.target)
+	}
+}
+
+// watchCalls records the end of campaign calls.
+func (c *Campaign) watchCalls(sub *callsystem.Subscription) {
+	for event := range sub.Events() {
+		reason := callsystem.EndReasonUnknown
+		if info, ok := event.Data.(*callsystem.EndInfo); ok && info != nil {
+			reason = info.Reason
+		}
+		c.callEnded(event.CallID, reason)
+	}
+}
+
+// callEnded completes the attempt for a campaign call.
+func (c *Campaign) callEnded(callID string, reason callsystem.EndReason) {
+	c.mu.Lock()
+	state, ok := c.calls[callID]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.calls, callID)
+	state.timer.Stop()
+
+	attempt := state.attempts[len(state.attempts)-1]
+	state.attempts = state.attempts[:len(state.attempts)-1]
+	c.mu.Unlock()
+
+	c.finish(state, attempt, reason)
+}
+
+// finish records an attempt, frees its call slot and schedules a retry or
+// settles the target.
+func (c *Campaign) finish(state *targetState, attempt Attempt, reason callsystem.EndReason) {
+	attempt.EndTime = time.Now()
+	attempt.EndReason = reason
+
+	c.mu.Lock()
+	state.attempts = append(state.attempts, attempt)
+	c.active--
+
+	n := len(state.attempts)
+	switch {
+	case c.retries(reason) && n < c.config.Retry.MaxAttempts:
+		state.status = StatusPending
+		state.nextAttempt = attempt.EndTime.Add(c.backoff(n))
+	case connected(reason):
+		state.status = StatusCompleted
+		state.nextAttempt = time.Time{}
+	default:
+		state.status = StatusFailed
+		state.nextAttempt = time.Time{}
+	}
+	outcome := state.outcome()
+	c.mu.Unlock()
+
+	c.notify()
+	if outcome.Status.final() && c.config.OnOutcome != nil {
+		c.config.OnOutcome(outcome)
+	}
+}
+
+// cancelAttempt marks a target whose call was interrupted by cancellation.
+func (c *Campaign) cancelAttempt(state *targetState) {
+	c.mu.Lock()
+	state.status = StatusCanceled
+	state.nextAttempt = time.Time{}
+	c.active--
+	outcome := state.outcome()
+	c.mu.Unlock()
+
+	c.notify()
+	if c.config.OnOutcome != nil {
+		c.config.OnOutcome(outcome)
+	}
+}
+
+// cancelPending marks all pending targets canceled.
+func (c *Campaign) cancelPending() {
+	c.mu.Lock()
+	var outcomes []Outcome
+	for _, state := range c.targets {
+		if state.status != StatusPending {
+			continue
+		}
+		state.status = StatusCanceled
+		state.nextAttempt = time.Time{}
+		outcomes = append(outcomes, state.outcome())
+	}
+	c.mu.Unlock()
+
+	if c.config.OnOutcome != nil {
+		for _, outcome := range outcomes {
+			c.config.OnOutcome(outcome)
+		}
+	}
+}
+
+// retries reports whether reason is retried.
+func (c *Campaign) retries(reason callsystem.EndReason) bool {
+	for _, r := range c.config.Retry.RetryOn {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay after the given number of attempts.
+func (c *Campaign) backoff(attempts int) time.Duration {
+	delays := c.config.Retry.Backoff
+	if attempts > len(delays) {
+		return delays[len(delays)-1]
+	}
+	return delays[attempts-1]
+}
+
+// connected reports whether a call that ended with reason was answered.
+func connected(reason callsystem.EndReason) bool {
+	switch reason {
+	case callsystem.EndReasonBusy, callsystem.EndReasonNoAnswer, callsystem.EndReasonFailed,
+		callsystem.EndReasonCanceled, callsystem.EndReasonRejected, callsystem.EndReasonUnknown:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,73 @@
+package dialer
+
+import (
+	"fmt"
+	"time"
+)
+
+// Window is a daily period during which targets may be called, in the
+// target's time zone.
+type Window struct {
+	// Days the window applies to. Empty means every day.
+	Days []time.Weekday
+
+	// Start and End are offsets from local midnight, e.g. 9*time.Hour
+	// and 17*time.Hour for 9am to 5pm. End must be after Start.
+	Start time.Duration
+	End   time.Duration
+}
+
+// validate checks the window bounds.
+func (w Window) validate() error {
+	if w.Start < 0 || w.End > 24*time.Hour || w.End <= w.Start {
+		return fmt.Errorf("invalid calling window %s-%s", w.Start, w.End)
+	}
+	return nil
+}
+
+// allows reports whether the window applies on day.
+func (w Window) allows(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// nextOpen returns the earliest time at or after t that falls inside one
+// of windows in loc. With no windows it returns t.
+func nextOpen(t time.Time, windows []Window, loc *time.Location) time.Time {
+	if len(windows) == 0 {
+		return t
+	}
+
+	local := t.In(loc)
+	var next time.Time
+	for d := 0; d <= 7; d++ {
+		midnight := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, loc)
+		for _, w := range windows {
+			if !w.allows(midnight.Weekday()) {
+				continue
+			}
+			start := midnight.Add(w.Start)
+			end := midnight.Add(w.End)
+			if !local.Before(end) {
+				continue
+			}
+			if !local.Before(start) {
+				return t
+			}
+			if next.IsZero() || start.Before(next) {
+				next = start
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return next
+}
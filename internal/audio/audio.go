@@ -0,0 +1,217 @@
+// Package audio provides audio format conversion for internal use.
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// MulawDecode decodes G.711 μ-law bytes to 16-bit linear PCM samples.
+func MulawDecode(src []byte) []int16 {
+	samples := make([]int16, len(src))
+	for i, b := range src {
+		samples[i] = mulawToLinear(b)
+	}
+	return samples
+}
+
+// MulawEncode encodes 16-bit linear PCM samples as G.711 μ-law bytes.
+func MulawEncode(samples []int16) []byte {
+	dst := make([]byte, len(samples))
+	for i, s := range samples {
+		dst[i] = linearToMulaw(s)
+	}
+	return dst
+}
+
+// mulawToLinear decodes a single μ-law byte.
+func mulawToLinear(b byte) int16 {
+	b = ^b
+	sign := b & 0x80
+	exponent := (b >> 4) & 0x07
+	mantissa := b & 0x0F
+
+	sample := ((int32(mantissa) << 3) + 0x84) << exponent
+	sample -= 0x84
+	if sign != 0 {
+		return int16(-sample)
+	}
+	return int16(sample)
+}
+
+// linearToMulaw encodes a single 16-bit sample.
+func linearToMulaw(sample int16) byte {
+	const (
+		bias = 0x84
+		clip = 32635
+	)
+
+	s := int32(sample)
+	var sign byte
+	if s < 0 {
+		sign = 0x80
+		s = -s
+	}
+	if s > clip {
+		s = clip
+	}
+	s += bias
+
+	exponent := byte(7)
+	for mask := int32(0x4000); s&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := byte(s>>(exponent+3)) & 0x0F
+
+	return ^(sign | exponent<<4 | mantissa)
+}
+
+// Resampler converts a stream of samples between sample rates by linear
+// interpolation. It keeps its position and filter state across calls, so
+// audio processed in chunks is resampled as if it were contiguous, for any
+// ratio of rates. Audio is low-pass filtered below the lower rate's
+// Nyquist frequency to limit aliasing and imaging.
+type Resampler struct {
+	from, to int
+	step     float64 // input samples per output sample
+	pos      float64 // position of the next output sample; 0 is prev
+	prev     float64 // last input sample of the previous chunk
+	hasPrev  bool
+	filter   lowpass
+}
+
+// NewResampler creates a resampler from one sample rate to another.
+func NewResampler(from, to int) *Resampler {
+	r := &Resampler{from: from, to: to}
+	if from <= 0 || to <= 0 || from == to {
+		return r
+	}
+	r.step = float64(from) / float64(to)
+
+	// Filter at the higher rate: the input when downsampling, the output
+	// when upsampling
+	rate, cutoff := from, to
+	if to > from {
+		rate, cutoff = to, from
+	}
+	r.filter = newLowpass(float64(rate), 0.45*float64(cutoff))
+	return r
+}
+
+// Process resamples the next chunk of samples.
+func (r *Resampler) Process(samples []int16) []int16 {
+	if r.step == 0 {
+		return samples
+	}
+
+	downsampling := r.to < r.from
+	in := make([]float64, 0, len(samples)+1)
+	if r.hasPrev {
+		in = append(in, r.prev)
+	}
+	for _, s := range samples {
+		v := float64(s)
+		if downsampling {
+			v = r.filter.process(v)
+		}
+		in = append(in, v)
+	}
+	if len(in) < 2 {
+		if len(in) == 1 {
+			r.prev, r.hasPrev = in[0], true
+		}
+		return nil
+	}
+
+	last := len(in) - 1
+	out := make([]int16, 0, int(float64(last)/r.step)+1)
+	for ; r.pos < float64(last); r.pos += r.step {
+		idx := int(r.pos)
+		frac := r.pos - float64(idx)
+		v := in[idx]*(1-frac) + in[idx+1]*frac
+		if !downsampling {
+			v = r.filter.process(v)
+		}
+		out = append(out, clamp16(v))
+	}
+	r.pos -= float64(last)
+	r.prev, r.hasPrev = in[last], true
+	return out
+}
+
+// lowpass is a fourth-order Butterworth low-pass filter made of two
+// biquad sections.
+type lowpass struct {
+	sections [2]biquad
+}
+
+// newLowpass creates a filter for sample rate with cutoff in Hz.
+func newLowpass(rate, cutoff float64) lowpass {
+	return lowpass{sections: [2]biquad{
+		newBiquad(rate, cutoff, 0.5411961),
+		newBiquad(rate, cutoff, 1.3065630),
+	}}
+}
+
+func (f *lowpass) process(x float64) float64 {
+	for i := range f.sections {
+		x = f.sections[i].process(x)
+	}
+	return x
+}
+
+// biquad is a second-order low-pass section (RBJ cookbook form).
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func newBiquad(rate, cutoff, q float64) biquad {
+	w0 := 2 * math.Pi * cutoff / rate
+	cos, alpha := math.Cos(w0), math.Sin(w0)/(2*q)
+	a0 := 1 + alpha
+	return biquad{
+		b0: (1 - cos) / 2 / a0,
+		b1: (1 - cos) / a0,
+		b2: (1 - cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (b *biquad) process(x float64) float64 {
+	y := b.b0*x + b.b1*b.x1 + b.b2*b.x2 - b.a1*b.y1 - b.a2*b.y2
+	b.x2, b.x1 = b.x1, x
+	b.y2, b.y1 = b.y1, y
+	return y
+}
+
+// clamp16 rounds v to the nearest 16-bit sample.
+func clamp16(v float64) int16 {
+	switch {
+	case v > math.MaxInt16:
+		return math.MaxInt16
+	case v < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
+
+// PCM16Bytes encodes samples as 16-bit little-endian PCM.
+func PCM16Bytes(samples []int16) []byte {
+	dst := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(dst[i*2:], uint16(s))
+	}
+	return dst
+}
+
+// PCM16Samples decodes 16-bit little-endian PCM. A trailing odd byte is
+// ignored.
+func PCM16Samples(src []byte) []int16 {
+	samples := make([]int16, len(src)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(src[i*2:]))
+	}
+	return samples
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Account represents a Twilio account or subaccount.
+type Account struct {
+	SID             string `json:"sid"`
+	FriendlyName    string `json:"friendly_name"`
+	Status          string `json:"status"` // "active", "suspended" or "closed"
+	Type            string `json:"type"`
+	OwnerAccountSID string `json:"owner_account_sid"`
+	AuthToken       string `json:"auth_token"`
+	DateCreated     string `json:"date_created"`
+}
+
+// AccountList is a page of accounts.
+type AccountList struct {
+	Accounts    []Account `json:"accounts"`
+	NextPageURI string    `json:"next_page_uri"`
+}
+
+// CreateSubaccount creates a subaccount of the client's account.
+func (c *Client) CreateSubaccount(ctx context.Context, friendlyName string) (*Account, error) {
+	endpoint := fmt.Sprintf("%s/Accounts.json", c.baseURL)
+
+	data := url.Values{}
+	if friendlyName != "" {
+		data.Set("FriendlyName", friendlyName)
+	}
+
+	var account Account
+	if err := c.post(ctx, endpoint, data, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetAccount retrieves an account the client has access to, such as one of
+// its subaccounts.
+func (c *Client) GetAccount(ctx context.Context, accountSID string) (*Account, error) {
+	endpoint := fmt.Sprintf("%s/Accounts/%s.json", c.baseURL, accountSID)
+
+	var account Account
+	if err := c.get(ctx, endpoint, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListSubaccounts returns the subaccounts of the client's account,
+// following pagination.
+func (c *Client) ListSubaccounts(ctx context.Context) ([]Account, error) {
+	endpoint := fmt.Sprintf("%s/Accounts.json?PageSize=1000", c.baseURL)
+
+	var accounts []Account
+	for endpoint != "" {
+		var list AccountList
+		if err := c.get(ctx, endpoint, &list); err != nil {
+			return nil, err
+		}
+		for _, account := range list.Accounts {
+			// The listing includes the parent account itself
+			if account.SID != c.accountSID {
+				accounts = append(accounts, account)
+			}
+		}
+
+		endpoint = ""
+		if list.NextPageURI != "" {
+			next, err := c.resolve(list.NextPageURI)
+			if err != nil {
+				return nil, err
+			}
+			endpoint = next
+		}
+	}
+	return accounts, nil
+}
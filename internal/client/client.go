@@ -4,21 +4,38 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	twilio "github.com/agentplexus/omnivoice-twilio"
+	"github.com/agentplexus/omnivoice-twilio/credentials"
+	"github.com/agentplexus/omnivoice-twilio/logging"
+	"github.com/agentplexus/omnivoice-twilio/metrics"
+	"github.com/agentplexus/omnivoice-twilio/tracing"
 )
 
 // Client is a Twilio API client.
 type Client struct {
-	accountSID string
-	authToken  string
-	baseURL    string
-	httpClient *http.Client
+	accountSID  string
+	credentials credentials.Provider
+	region      string
+	edge        string
+	baseURL     string
+	httpClient  *http.Client
+	logger      *slog.Logger
+	requests    metrics.Counter
+	latency     metrics.Histogram
+	tracer      tracing.Tracer
+
+	lookupBaseURL string
 }
 
 // Config configures the Twilio client.
@@ -26,7 +43,48 @@ type Config struct {
 	AccountSID string
 	AuthToken  string
 	BaseURL    string
+
+	// APIKeySID and APIKeySecret authenticate with an API key (SK...)
+	// instead of the auth token. AccountSID is still required.
+	APIKeySID    string
+	APIKeySecret string
+
+	// Credentials, if set, is consulted for every request instead of the
+	// static AuthToken and API key. AccountSID defaults to its account.
+	Credentials credentials.Provider
+
+	// Region and Edge select the Twilio Region (e.g. "ie1") and Edge
+	// location (e.g. "dublin") that requests are sent to. Each non-US
+	// region has its own credentials. BaseURL and LookupBaseURL, if set,
+	// take precedence.
+	Region string
+	Edge   string
+
 	HTTPClient *http.Client
+
+	// Logger receives request logs. Defaults to discarding them.
+	Logger *slog.Logger
+
+	// Meter receives request counts and latencies.
+	Meter metrics.Meter
+
+	// Tracer receives a span per request, a child of the span in the
+	// request context.
+	Tracer tracing.Tracer
+
+	// LookupBaseURL is the Lookup v2 API base URL.
+	LookupBaseURL string
+}
+
+// validLocation reports whether a region or edge name can be used in a
+// hostname.
+func validLocation(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
 }
 
 // New creates a new Twilio client.
@@ -35,7 +93,31 @@ func New(cfg *Config) (*Client, error) {
 		cfg = &Config{}
 	}
 
+	region := cfg.Region
+	if region == "" {
+		region = os.Getenv("TWILIO_REGION")
+	}
+	edge := cfg.Edge
+	if edge == "" {
+		edge = os.Getenv("TWILIO_EDGE")
+	}
+	region, edge = strings.ToLower(region), strings.ToLower(edge)
+	if !validLocation(region) {
+		return nil, fmt.Errorf("invalid region %q", region)
+	}
+	if !validLocation(edge) {
+		return nil, fmt.Errorf("invalid edge %q", edge)
+	}
+
 	accountSID := cfg.AccountSID
+	provider := cfg.Credentials
+	if provider != nil && accountSID == "" {
+		creds, err := provider.Credentials(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials: %w", err)
+		}
+		accountSID = creds.AccountSID
+	}
 	if accountSID == "" {
 		accountSID = os.Getenv("TWILIO_ACCOUNT_SID")
 	}
@@ -43,19 +125,49 @@ func New(cfg *Config) (*Client, error) {
 		return nil, fmt.Errorf("TWILIO_ACCOUNT_SID is required")
 	}
 
-	authToken := cfg.AuthToken
-	if authToken == "" {
-		authToken = os.Getenv("TWILIO_AUTH_TOKEN")
-	}
-	if authToken == "" {
-		return nil, fmt.Errorf("TWILIO_AUTH_TOKEN is required")
+	if provider == nil {
+		creds := credentials.Credentials{
+			AccountSID:   accountSID,
+			AuthToken:    cfg.AuthToken,
+			APIKeySID:    cfg.APIKeySID,
+			APIKeySecret: cfg.APIKeySecret,
+		}
+		if creds.APIKeySID == "" {
+			creds.APIKeySID = os.Getenv("TWILIO_API_KEY")
+			creds.APIKeySecret = os.Getenv("TWILIO_API_SECRET")
+		}
+
+		// Regional auth tokens can be kept alongside the US1 one, e.g. in
+		// TWILIO_AUTH_TOKEN_IE1
+		if creds.AuthToken == "" && region != "" {
+			creds.AuthToken = os.Getenv("TWILIO_AUTH_TOKEN_" + strings.ToUpper(region))
+		}
+		if creds.AuthToken == "" {
+			creds.AuthToken = os.Getenv("TWILIO_AUTH_TOKEN")
+		}
+		creds.SecondaryAuthToken = os.Getenv("TWILIO_SECONDARY_AUTH_TOKEN")
+
+		if creds.APIKeySID == "" && creds.AuthToken == "" {
+			return nil, fmt.Errorf("TWILIO_AUTH_TOKEN or an API key is required")
+		}
+		if err := creds.Validate(); err != nil {
+			return nil, err
+		}
+		provider = credentials.Static(creds)
 	}
 
 	baseURL := cfg.BaseURL
 	if baseURL == "" {
-		baseURL = "https://api.twilio.com/2010-04-01"
+		baseURL = twilio.APIBaseURL(region, edge)
 	}
 
+	lookupBaseURL := cfg.LookupBaseURL
+	if lookupBaseURL == "" {
+		lookupBaseURL = "https://" + twilio.Hostname("lookups", region, edge) + "/v2"
+	}
+
+	meter := metrics.OrNoop(cfg.Meter)
+
 	httpClient := cfg.HTTPClient
 	if httpClient == nil {
 		httpClient = &http.Client{
@@ -64,10 +176,20 @@ func New(cfg *Config) (*Client, error) {
 	}
 
 	return &Client{
-		accountSID: accountSID,
-		authToken:  authToken,
-		baseURL:    baseURL,
-		httpClient: httpClient,
+		accountSID:  accountSID,
+		credentials: provider,
+		region:      region,
+		edge:        edge,
+		baseURL:     baseURL,
+		httpClient:  httpClient,
+		logger:      logging.Logger(cfg.Logger),
+		requests: meter.Counter(metrics.RESTRequests, "Twilio REST API requests.",
+			metrics.LabelEndpoint, metrics.LabelMethod, metrics.LabelStatusCode, metrics.LabelErrorCode),
+		latency: meter.Histogram(metrics.RESTDuration, "Twilio REST API request latency in seconds.",
+			metrics.DefaultBuckets, metrics.LabelEndpoint, metrics.LabelMethod),
+		tracer: tracing.OrNoop(cfg.Tracer),
+
+		lookupBaseURL: lookupBaseURL,
 	}, nil
 }
 
@@ -76,6 +198,40 @@ func (c *Client) AccountSID() string {
 	return c.accountSID
 }
 
+// Region returns the Twilio Region requests are sent to, or "" for the
+// default.
+func (c *Client) Region() string {
+	return c.region
+}
+
+// Edge returns the Twilio Edge location requests are sent to, or "" for
+// the default.
+func (c *Client) Edge() string {
+	return c.edge
+}
+
+// Credentials returns the current credentials.
+func (c *Client) Credentials(ctx context.Context) (credentials.Credentials, error) {
+	creds, err := c.credentials.Credentials(ctx)
+	if err != nil {
+		return credentials.Credentials{}, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// CredentialsProvider returns the provider consulted for credentials.
+func (c *Client) CredentialsProvider() credentials.Provider {
+	return c.credentials
+}
+
+// ForAccount returns a client for another account, typically a
+// subaccount, using the same credentials.
+func (c *Client) ForAccount(accountSID string) *Client {
+	clone := *c
+	clone.accountSID = accountSID
+	return &clone
+}
+
 // Call represents a Twilio call resource.
 type Call struct {
 	SID         string `json:"sid"`
@@ -106,9 +262,20 @@ type MakeCallParams struct {
 	StatusCallbackEvent []string          // Events to receive
 	MachineDetection    string            // "Enable" or "DetectMessageEnd"
 	Timeout             int               // Ring timeout in seconds
+	TimeLimit           int               // Maximum call duration in seconds
 	Record              bool              // Record the call
 	RecordingChannels   string            // "mono" or "dual"
 	CustomParameters    map[string]string // Custom parameters
+	SipAuthUsername     string            // Username for SIP targets that challenge
+	SipAuthPassword     string            // Password for SIP targets that challenge
+
+	// Answering machine detection tuning (used when MachineDetection is set)
+	AsyncAmd                           bool   // Run detection without blocking TwiML execution
+	AsyncAmdCallback                   string // Webhook for async detection results
+	MachineDetectionTimeout            int    // Seconds to wait for a result
+	MachineDetectionSpeechThreshold    int    // Milliseconds of speech that indicates a machine
+	MachineDetectionSpeechEndThreshold int    // Milliseconds of silence that ends speech
+	MachineDetectionSilenceTimeout     int    // Milliseconds of initial silence before "unknown"
 }
 
 // MakeCall initiates an outbound call.
@@ -133,16 +300,41 @@ func (c *Client) MakeCall(ctx context.Context, params *MakeCallParams) (*Call, e
 	}
 	if params.MachineDetection != "" {
 		data.Set("MachineDetection", params.MachineDetection)
+		if params.MachineDetectionTimeout > 0 {
+			data.Set("MachineDetectionTimeout", fmt.Sprintf("%d", params.MachineDetectionTimeout))
+		}
+		if params.MachineDetectionSpeechThreshold > 0 {
+			data.Set("MachineDetectionSpeechThreshold", fmt.Sprintf("%d", params.MachineDetectionSpeechThreshold))
+		}
+		if params.MachineDetectionSpeechEndThreshold > 0 {
+			data.Set("MachineDetectionSpeechEndThreshold", fmt.Sprintf("%d", params.MachineDetectionSpeechEndThreshold))
+		}
+		if params.MachineDetectionSilenceTimeout > 0 {
+			data.Set("MachineDetectionSilenceTimeout", fmt.Sprintf("%d", params.MachineDetectionSilenceTimeout))
+		}
+		if params.AsyncAmd {
+			data.Set("AsyncAmd", "true")
+		}
+		if params.AsyncAmdCallback != "" {
+			data.Set("AsyncAmdStatusCallback", params.AsyncAmdCallback)
+		}
 	}
 	if params.Timeout > 0 {
 		data.Set("Timeout", fmt.Sprintf("%d", params.Timeout))
 	}
+	if params.TimeLimit > 0 {
+		data.Set("TimeLimit", fmt.Sprintf("%d", params.TimeLimit))
+	}
 	if params.Record {
 		data.Set("Record", "true")
 	}
 	if params.RecordingChannels != "" {
 		data.Set("RecordingChannels", params.RecordingChannels)
 	}
+	if params.SipAuthUsername != "" {
+		data.Set("SipAuthUsername", params.SipAuthUsername)
+		data.Set("SipAuthPassword", params.SipAuthPassword)
+	}
 	for k, v := range params.CustomParameters {
 		data.Set(k, v)
 	}
@@ -167,9 +359,10 @@ func (c *Client) GetCall(ctx context.Context, callSID string) (*Call, error) {
 
 // UpdateCallParams are parameters for updating a call.
 type UpdateCallParams struct {
-	URL    string // New TwiML URL
-	Twiml  string // Inline TwiML
-	Status string // "completed" to hang up, "canceled" to cancel
+	URL       string // New TwiML URL
+	Twiml     string // Inline TwiML
+	Status    string // "completed" to hang up, "canceled" to cancel
+	TimeLimit int    // Maximum call duration in seconds
 }
 
 // UpdateCall modifies an in-progress call.
@@ -186,6 +379,9 @@ func (c *Client) UpdateCall(ctx context.Context, callSID string, params *UpdateC
 	if params.Status != "" {
 		data.Set("Status", params.Status)
 	}
+	if params.TimeLimit > 0 {
+		data.Set("TimeLimit", fmt.Sprintf("%d", params.TimeLimit))
+	}
 
 	var call Call
 	if err := c.post(ctx, endpoint, data, &call); err != nil {
@@ -214,17 +410,97 @@ type PhoneNumber struct {
 // PhoneNumberList is a list of phone numbers.
 type PhoneNumberList struct {
 	PhoneNumbers []PhoneNumber `json:"incoming_phone_numbers"`
+	NextPageURI  string        `json:"next_page_uri"`
 }
 
-// ListPhoneNumbers returns all phone numbers on the account.
+// ListPhoneNumbers returns all phone numbers on the account, following
+// pagination.
 func (c *Client) ListPhoneNumbers(ctx context.Context) ([]PhoneNumber, error) {
-	endpoint := fmt.Sprintf("%s/Accounts/%s/IncomingPhoneNumbers.json", c.baseURL, c.accountSID)
+	endpoint := fmt.Sprintf("%s/Accounts/%s/IncomingPhoneNumbers.json?PageSize=1000", c.baseURL, c.accountSID)
 
-	var list PhoneNumberList
-	if err := c.get(ctx, endpoint, &list); err != nil {
-		return nil, err
+	var numbers []PhoneNumber
+	for endpoint != "" {
+		var list PhoneNumberList
+		if err := c.get(ctx, endpoint, &list); err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, list.PhoneNumbers...)
+
+		endpoint = ""
+		if list.NextPageURI != "" {
+			next, err := c.resolve(list.NextPageURI)
+			if err != nil {
+				return nil, err
+			}
+			endpoint = next
+		}
 	}
-	return list.PhoneNumbers, nil
+	return numbers, nil
+}
+
+// resolve resolves a URI returned by the API (e.g. next_page_uri) against
+// the base URL.
+func (c *Client) resolve(uri string) (string, error) {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid page URI %q: %w", uri, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// Endpoint returns a URL path with SIDs and phone numbers replaced by
+// placeholders, e.g. "/2010-04-01/Accounts/{Sid}/Calls/{Sid}.json", for
+// logs and metrics.
+func Endpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		name, ext, _ := strings.Cut(segment, ".")
+		if ext != "" {
+			ext = "." + ext
+		}
+		switch {
+		case isSID(name):
+			segments[i] = "{Sid}" + ext
+		case isPhoneNumber(name):
+			segments[i] = "{PhoneNumber}" + ext
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// isSID reports whether s looks like a Twilio SID: two letters and 32 hex
+// digits.
+func isSID(s string) bool {
+	if len(s) != 34 {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case i < 2 && r >= 'A' && r <= 'Z':
+		case i >= 2 && (r >= '0' && r <= '9' || r >= 'a' && r <= 'f'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isPhoneNumber reports whether s looks like a phone number.
+func isPhoneNumber(s string) bool {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "+"), "%2B")
+	if len(s) < 7 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 // Error represents a Twilio API error.
@@ -239,6 +515,27 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("twilio error %d: %s", e.Code, e.Message)
 }
 
+// ErrorAttr returns err as a log attribute without the phone numbers a
+// request error can carry: API errors, whose messages may quote the
+// request, are logged by code, and request errors without their URL.
+func ErrorAttr(err error) slog.Attr {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return slog.Int("twilio_code", apiErr.Code)
+	}
+	return logging.Error(withoutURL(err))
+}
+
+// withoutURL returns the error wrapped by a *url.Error, whose full URL may
+// contain a phone number, or err itself.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
 // get performs a GET request.
 func (c *Client) get(ctx context.Context, url string, result any) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -260,30 +557,78 @@ func (c *Client) post(ctx context.Context, url string, data url.Values, result a
 
 // do executes a request with authentication.
 func (c *Client) do(req *http.Request, result any) error {
-	req.SetBasicAuth(c.accountSID, c.authToken)
+	start := time.Now()
+	endpoint := Endpoint(req.URL.Path)
+
+	ctx, span := c.tracer.Start(req.Context(), tracing.SpanREST)
+	defer span.End()
+	span.SetAttribute(tracing.AttrHTTPMethod, req.Method)
+	span.SetAttribute(tracing.AttrURLTemplate, endpoint)
+	req = req.WithContext(ctx)
+
+	creds, err := c.Credentials(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if creds.AccountSID == "" {
+		creds.AccountSID = c.accountSID
+	}
+	req.SetBasicAuth(creds.BasicAuth())
 	req.Header.Set("Accept", "application/json")
 
+	logger := c.logger.With(
+		slog.String(logging.KeyMethod, req.Method),
+		slog.String(logging.KeyPath, endpoint),
+	)
+
 	resp, err := c.httpClient.Do(req)
+	c.latency.Observe(time.Since(start).Seconds(), endpoint, req.Method)
 	if err != nil {
+		c.requests.Add(1, endpoint, req.Method, "error", "")
+		// The path attribute is already templated
+		logger.WarnContext(ctx, "twilio request failed", ErrorAttr(err))
+		span.RecordError(withoutURL(err))
 		return err
 	}
 	defer func() { _ = resp.Body.Close() }()
+	span.SetAttribute(tracing.AttrHTTPStatusCode, resp.StatusCode)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
+		logger.WarnContext(ctx, "failed to read twilio response", logging.Error(err))
+		span.RecordError(err)
 		return err
 	}
 
+	logger = logger.With(
+		slog.Int(logging.KeyStatusCode, resp.StatusCode),
+		slog.Duration(logging.KeyDuration, time.Since(start)),
+	)
+
+	statusCode := strconv.Itoa(resp.StatusCode)
 	if resp.StatusCode >= 400 {
 		var apiErr Error
 		if err := json.Unmarshal(body, &apiErr); err != nil {
-			return fmt.Errorf("twilio error: %s", string(body))
+			c.requests.Add(1, endpoint, req.Method, statusCode, "")
+			logger.WarnContext(ctx, "twilio request failed")
+			// The body is not an API error and may echo request data
+			err := fmt.Errorf("twilio error: unexpected response with status %d", resp.StatusCode)
+			span.RecordError(err)
+			return err
 		}
+		c.requests.Add(1, endpoint, req.Method, statusCode, strconv.Itoa(apiErr.Code))
+		logger.WarnContext(ctx, "twilio request failed", slog.Int("twilio_code", apiErr.Code))
+		span.SetAttribute(tracing.AttrErrorCode, apiErr.Code)
+		span.RecordError(&apiErr)
 		return &apiErr
 	}
+	c.requests.Add(1, endpoint, req.Method, statusCode, "")
+	logger.DebugContext(ctx, "twilio request")
 
 	if result != nil {
 		if err := json.Unmarshal(body, result); err != nil {
+			span.RecordError(err)
 			return fmt.Errorf("failed to parse response: %w", err)
 		}
 	}
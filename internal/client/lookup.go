@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Lookup v2 data packages.
+const (
+	LookupLineTypeIntelligence = "line_type_intelligence"
+	LookupCallerName           = "caller_name"
+)
+
+// PhoneNumberLookup is a Lookup v2 phone number resource.
+type PhoneNumberLookup struct {
+	PhoneNumber          string                `json:"phone_number"`
+	NationalFormat       string                `json:"national_format"`
+	CountryCode          string                `json:"country_code"`
+	CallingCountryCode   string                `json:"calling_country_code"`
+	Valid                bool                  `json:"valid"`
+	ValidationErrors     []string              `json:"validation_errors"`
+	CallerName           *CallerNameInfo       `json:"caller_name"`
+	LineTypeIntelligence *LineTypeIntelligence `json:"line_type_intelligence"`
+	URL                  string                `json:"url"`
+}
+
+// CallerNameInfo is the caller_name data package.
+type CallerNameInfo struct {
+	CallerName string `json:"caller_name"`
+	CallerType string `json:"caller_type"` // "BUSINESS", "CONSUMER" or "UNDETERMINED"
+	ErrorCode  *int   `json:"error_code"`
+}
+
+// LineTypeIntelligence is the line_type_intelligence data package.
+type LineTypeIntelligence struct {
+	Type              string `json:"type"` // e.g. "mobile", "landline", "nonFixedVoip"
+	CarrierName       string `json:"carrier_name"`
+	MobileCountryCode string `json:"mobile_country_code"`
+	MobileNetworkCode string `json:"mobile_network_code"`
+	ErrorCode         *int   `json:"error_code"`
+}
+
+// LookupPhoneNumber looks up a phone number, requesting the given data
+// packages (e.g. LookupLineTypeIntelligence) in addition to basic
+// validation.
+func (c *Client) LookupPhoneNumber(ctx context.Context, number string, fields ...string) (*PhoneNumberLookup, error) {
+	endpoint := fmt.Sprintf("%s/PhoneNumbers/%s", c.lookupBaseURL, url.PathEscape(number))
+	if len(fields) > 0 {
+		endpoint += "?Fields=" + url.QueryEscape(strings.Join(fields, ","))
+	}
+
+	var lookup PhoneNumberLookup
+	if err := c.get(ctx, endpoint, &lookup); err != nil {
+		return nil, err
+	}
+	return &lookup, nil
+}
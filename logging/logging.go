@@ -0,0 +1,183 @@
+// Package logging provides the attribute keys and redaction used by the
+// log/slog output of this module's packages.
+//
+// Each package accepts a *slog.Logger with a WithLogger option. Loggers are
+// wrapped with NewHandler and DefaultPolicy unless they already redact, so
+// to choose a different policy wrap the handler yourself:
+//
+//	logger := slog.New(logging.NewHandler(slog.NewJSONHandler(os.Stderr, nil), logging.Policy{
+//	    PhoneNumbers: logging.PhoneNumbersFull,
+//	}))
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+// Attribute keys used across packages.
+const (
+	KeyCallSID    = "call_sid"
+	KeyStreamSID  = "stream_sid"
+	KeyAccountSID = "account_sid"
+	KeyTenant     = "tenant"
+	KeyStatus     = "status"
+	KeyReason     = "reason"
+	KeyDirection  = "direction"
+	KeyFrom       = "from"
+	KeyTo         = "to"
+	KeyNumber     = "number"
+	KeyMethod     = "method"
+	KeyPath       = "path"
+	KeyStatusCode = "status_code"
+	KeyDuration   = "duration"
+	KeyError      = "error"
+)
+
+// phoneKeys are attribute keys whose values are phone numbers or other
+// call targets.
+var phoneKeys = map[string]bool{
+	KeyFrom:   true,
+	KeyTo:     true,
+	KeyNumber: true,
+	"caller":  true,
+	"called":  true,
+}
+
+// secretMarkers identify attribute keys whose values are always redacted.
+var secretMarkers = []string{"token", "secret", "password", "authorization"}
+
+// Redacted replaces redacted values.
+const Redacted = "[REDACTED]"
+
+// PhoneNumberMode controls how phone numbers are logged.
+type PhoneNumberMode string
+
+// Phone number modes.
+const (
+	// PhoneNumbersMasked keeps the last four digits, e.g. "+1*******4567".
+	PhoneNumbersMasked PhoneNumberMode = "masked"
+
+	// PhoneNumbersHidden replaces phone numbers with Redacted.
+	PhoneNumbersHidden PhoneNumberMode = "hidden"
+
+	// PhoneNumbersFull logs phone numbers unchanged.
+	PhoneNumbersFull PhoneNumberMode = "full"
+)
+
+// Policy controls redaction. Secrets (attributes whose keys contain
+// "token", "secret", "password" or "authorization") are always redacted.
+type Policy struct {
+	// PhoneNumbers controls the from, to and number attributes. Defaults
+	// to PhoneNumbersMasked.
+	PhoneNumbers PhoneNumberMode
+}
+
+// DefaultPolicy masks phone numbers.
+var DefaultPolicy = Policy{PhoneNumbers: PhoneNumbersMasked}
+
+// MaskPhoneNumber replaces all but the last four digits of s with '*'.
+func MaskPhoneNumber(s string) string {
+	keep := 4
+	b := []byte(s)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < '0' || b[i] > '9' {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		b[i] = '*'
+	}
+	return string(b)
+}
+
+// redact applies the policy to an attribute.
+func (p Policy) redact(attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() == slog.KindGroup {
+		attrs := attr.Value.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, a := range attrs {
+			redacted[i] = p.redact(a)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	}
+
+	key := strings.ToLower(attr.Key)
+	for _, marker := range secretMarkers {
+		if strings.Contains(key, marker) {
+			return slog.String(attr.Key, Redacted)
+		}
+	}
+
+	if phoneKeys[key] {
+		switch p.PhoneNumbers {
+		case PhoneNumbersFull:
+		case PhoneNumbersHidden:
+			return slog.String(attr.Key, Redacted)
+		default:
+			return slog.String(attr.Key, MaskPhoneNumber(attr.Value.Resolve().String()))
+		}
+	}
+	return attr
+}
+
+// Handler redacts attributes according to a Policy before passing records
+// to another handler.
+type Handler struct {
+	next   slog.Handler
+	policy Policy
+}
+
+// NewHandler wraps next with redaction.
+func NewHandler(next slog.Handler, policy Policy) *Handler {
+	return &Handler{next: next, policy: policy}
+}
+
+// Enabled reports whether the wrapped handler handles level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle redacts the record's attributes and passes it on.
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.policy.redact(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs returns a handler with redacted attributes added.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.policy.redact(attr)
+	}
+	return &Handler{next: h.next.WithAttrs(redacted), policy: h.policy}
+}
+
+// WithGroup returns a handler that nests attributes in a group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), policy: h.policy}
+}
+
+// Logger returns logger wrapped with DefaultPolicy redaction unless it
+// already redacts, or a logger that discards everything if logger is nil.
+func Logger(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	if _, ok := logger.Handler().(*Handler); ok {
+		return logger
+	}
+	return slog.New(NewHandler(logger.Handler(), DefaultPolicy))
+}
+
+// Error returns an error attribute.
+func Error(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
@@ -0,0 +1,112 @@
+// Package metrics defines the instruments this module reports to and a
+// Prometheus text exposition adapter.
+//
+// Packages accept a Meter with a WithMeter option and report nothing by
+// default. Implement Meter to forward to OpenTelemetry or another
+// backend, or use NewRegistry to serve Prometheus metrics directly:
+//
+//	registry := metrics.NewRegistry()
+//	provider, _ := callsystem.New(callsystem.WithMeter(registry))
+//	http.Handle("/metrics", registry)
+package metrics
+
+// Metric names.
+const (
+	// RESTRequests counts REST API requests by endpoint, method, HTTP
+	// status and Twilio error code.
+	RESTRequests = "twilio_rest_requests_total"
+
+	// RESTDuration is REST API latency in seconds by endpoint and method.
+	RESTDuration = "twilio_rest_request_duration_seconds"
+
+	// CallsStarted counts calls created locally by direction.
+	CallsStarted = "twilio_calls_started_total"
+
+	// CallsEnded counts ended calls by direction and end reason.
+	CallsEnded = "twilio_calls_ended_total"
+
+	// CallsActive is the number of calls in progress by direction.
+	CallsActive = "twilio_calls_active"
+
+	// StreamsStarted counts Media Stream connections.
+	StreamsStarted = "twilio_streams_started_total"
+
+	// StreamsActive is the number of open Media Stream connections.
+	StreamsActive = "twilio_streams_active"
+
+	// AudioFrames counts audio frames by direction ("inbound" from the
+	// caller, "outbound" to the caller).
+	AudioFrames = "twilio_audio_frames_total"
+
+	// AudioFramesDropped counts audio frames dropped because a buffer was
+	// full, by direction.
+	AudioFramesDropped = "twilio_audio_frames_dropped_total"
+
+	// MarkRoundTrip is the time in seconds between sending a mark and
+	// Twilio reporting it played.
+	MarkRoundTrip = "twilio_mark_round_trip_seconds"
+)
+
+// Label names.
+const (
+	LabelEndpoint   = "endpoint"
+	LabelMethod     = "method"
+	LabelStatusCode = "status_code"
+	LabelErrorCode  = "error_code"
+	LabelDirection  = "direction"
+	LabelReason     = "reason"
+)
+
+// DefaultBuckets are histogram buckets in seconds suited to REST and
+// audio latencies.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Counter is a monotonically increasing value. Label values are given in
+// the order of the label names the counter was created with.
+type Counter interface {
+	Add(delta float64, labelValues ...string)
+}
+
+// Gauge is a value that can go up and down.
+type Gauge interface {
+	Add(delta float64, labelValues ...string)
+	Set(value float64, labelValues ...string)
+}
+
+// Histogram records a distribution of values.
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// Meter creates instruments. Asking for the same name twice must return
+// the same instrument, since several providers may share a Meter.
+// Implementations must be safe for concurrent use.
+type Meter interface {
+	Counter(name, help string, labelNames ...string) Counter
+	Gauge(name, help string, labelNames ...string) Gauge
+	Histogram(name, help string, buckets []float64, labelNames ...string) Histogram
+}
+
+// Noop returns a Meter whose instruments discard everything.
+func Noop() Meter {
+	return noop{}
+}
+
+type noop struct{}
+
+func (noop) Counter(string, string, ...string) Counter { return noop{} }
+func (noop) Gauge(string, string, ...string) Gauge     { return noop{} }
+func (noop) Histogram(string, string, []float64, ...string) Histogram {
+	return noop{}
+}
+func (noop) Add(float64, ...string)     {}
+func (noop) Set(float64, ...string)     {}
+func (noop) Observe(float64, ...string) {}
+
+// OrNoop returns meter, or Noop if it is nil.
+func OrNoop(meter Meter) Meter {
+	if meter == nil {
+		return Noop()
+	}
+	return meter
+}
@@ -0,0 +1,238 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry is a Meter that serves its metrics in the Prometheus text
+// exposition format.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*metric)}
+}
+
+// metricKind is the Prometheus metric type.
+type metricKind string
+
+const (
+	kindCounter   metricKind = "counter"
+	kindGauge     metricKind = "gauge"
+	kindHistogram metricKind = "histogram"
+)
+
+// metric is a metric family.
+type metric struct {
+	name       string
+	help       string
+	kind       metricKind
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is one combination of label values.
+type series struct {
+	labelValues []string
+	value       float64 // counters and gauges
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// get returns the family with name, creating it if needed.
+func (r *Registry) get(name, help string, kind metricKind, buckets []float64, labelNames []string) *metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, ok := r.metrics[name]; ok {
+		if m.kind != kind {
+			panic(fmt.Sprintf("metrics: %s registered as %s, requested as %s", name, m.kind, kind))
+		}
+		return m
+	}
+
+	m := &metric{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: append([]string(nil), labelNames...),
+		buckets:    append([]float64(nil), buckets...),
+		series:     make(map[string]*series),
+	}
+	sort.Float64s(m.buckets)
+	r.metrics[name] = m
+	return m
+}
+
+// Counter returns the counter with name.
+func (r *Registry) Counter(name, help string, labelNames ...string) Counter {
+	return r.get(name, help, kindCounter, nil, labelNames)
+}
+
+// Gauge returns the gauge with name.
+func (r *Registry) Gauge(name, help string, labelNames ...string) Gauge {
+	return r.get(name, help, kindGauge, nil, labelNames)
+}
+
+// Histogram returns the histogram with name. Buckets default to
+// DefaultBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return r.get(name, help, kindHistogram, buckets, labelNames)
+}
+
+// seriesLocked returns the series for label values, creating it if
+// needed. Missing values are empty and extra values are ignored. It must
+// be called with m.mu held.
+func (m *metric) seriesLocked(labelValues []string) *series {
+	values := make([]string, len(m.labelNames))
+	copy(values, labelValues)
+	key := strings.Join(values, "\xff")
+
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: values}
+		if m.kind == kindHistogram {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// Add adds delta to a counter or gauge.
+func (m *metric) Add(delta float64, labelValues ...string) {
+	if m.kind == kindCounter && delta < 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seriesLocked(labelValues).value += delta
+}
+
+// Set sets a gauge.
+func (m *metric) Set(value float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seriesLocked(labelValues).value = value
+}
+
+// Observe records a histogram value.
+func (m *metric) Observe(value float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.seriesLocked(labelValues)
+	for i, bound := range m.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.Write(w)
+}
+
+// Write writes the metrics in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := make([]*metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.mu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name < metrics[j].name })
+
+	var b strings.Builder
+	for _, m := range metrics {
+		m.write(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// write appends the family in the text format.
+func (m *metric) write(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", m.name, m.kind)
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := m.series[key]
+		if m.kind != kindHistogram {
+			fmt.Fprintf(b, "%s%s %s\n", m.name, m.labels(s.labelValues, ""), formatFloat(s.value))
+			continue
+		}
+		for i, bound := range m.buckets {
+			fmt.Fprintf(b, "%s_bucket%s %d\n", m.name, m.labels(s.labelValues, formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", m.name, m.labels(s.labelValues, "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", m.name, m.labels(s.labelValues, ""), formatFloat(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", m.name, m.labels(s.labelValues, ""), s.count)
+	}
+}
+
+// labels formats a label set, adding le for histogram buckets.
+func (m *metric) labels(values []string, le string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, name := range m.labelNames {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats a sample value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
@@ -0,0 +1,339 @@
+// Package phonenumber parses, normalizes and validates call targets: phone
+// numbers in E.164 or common national formats, SIP URIs and Twilio Client
+// identities.
+package phonenumber
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/agentplexus/omnivoice-twilio/logging"
+)
+
+// ErrInvalid is matched by all parse errors via errors.Is.
+var ErrInvalid = errors.New("invalid call target")
+
+// Reason classifies why input is invalid.
+type Reason string
+
+// Invalid input reasons.
+const (
+	ReasonEmpty              Reason = "empty"
+	ReasonInvalidCharacters  Reason = "invalid_characters"
+	ReasonTooShort           Reason = "too_short"
+	ReasonTooLong            Reason = "too_long"
+	ReasonInvalidCountryCode Reason = "invalid_country_code"
+	ReasonUnknownRegion      Reason = "unknown_region"
+	ReasonInvalidAreaCode    Reason = "invalid_area_code"
+	ReasonInvalidSIPURI      Reason = "invalid_sip_uri"
+	ReasonInvalidClient      Reason = "invalid_client_identity"
+)
+
+// Error describes invalid input. Its message masks the digits of Input,
+// which may be a phone number.
+type Error struct {
+	Input  string
+	Reason Reason
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid call target %q: %s", logging.MaskPhoneNumber(e.Input), strings.ReplaceAll(string(e.Reason), "_", " "))
+}
+
+// Unwrap returns ErrInvalid.
+func (e *Error) Unwrap() error {
+	return ErrInvalid
+}
+
+// invalid returns an *Error.
+func invalid(input string, reason Reason) error {
+	return &Error{Input: input, Reason: reason}
+}
+
+// E.164 limits.
+const (
+	minE164Digits = 7
+	maxE164Digits = 15
+
+	// maxClientIdentity is the longest Twilio Client identity.
+	maxClientIdentity = 121
+)
+
+// region describes the numbering plan of a region.
+type region struct {
+	countryCode int
+	trunkPrefix string // national prefix dropped when dialing internationally
+	minLength   int    // national significant number length
+	maxLength   int
+}
+
+// regions maps ISO 3166-1 alpha-2 region codes to numbering plans. Regions
+// not listed can still be dialed in E.164 format.
+var regions = map[string]region{
+	"US": {countryCode: 1, trunkPrefix: "1", minLength: 10, maxLength: 10},
+	"CA": {countryCode: 1, trunkPrefix: "1", minLength: 10, maxLength: 10},
+	"GB": {countryCode: 44, trunkPrefix: "0", minLength: 9, maxLength: 10},
+	"IE": {countryCode: 353, trunkPrefix: "0", minLength: 7, maxLength: 9},
+	"DE": {countryCode: 49, trunkPrefix: "0", minLength: 6, maxLength: 13},
+	"FR": {countryCode: 33, trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"ES": {countryCode: 34, minLength: 9, maxLength: 9},
+	"IT": {countryCode: 39, minLength: 6, maxLength: 11},
+	"NL": {countryCode: 31, trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"BE": {countryCode: 32, trunkPrefix: "0", minLength: 8, maxLength: 9},
+	"CH": {countryCode: 41, trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"AT": {countryCode: 43, trunkPrefix: "0", minLength: 4, maxLength: 13},
+	"SE": {countryCode: 46, trunkPrefix: "0", minLength: 7, maxLength: 9},
+	"PL": {countryCode: 48, minLength: 9, maxLength: 9},
+	"PT": {countryCode: 351, minLength: 9, maxLength: 9},
+	"AU": {countryCode: 61, trunkPrefix: "0", minLength: 9, maxLength: 9},
+	"NZ": {countryCode: 64, trunkPrefix: "0", minLength: 8, maxLength: 10},
+	"IN": {countryCode: 91, trunkPrefix: "0", minLength: 10, maxLength: 10},
+	"JP": {countryCode: 81, trunkPrefix: "0", minLength: 9, maxLength: 10},
+	"SG": {countryCode: 65, minLength: 8, maxLength: 8},
+	"BR": {countryCode: 55, trunkPrefix: "0", minLength: 10, maxLength: 11},
+	"MX": {countryCode: 52, minLength: 10, maxLength: 10},
+	"ZA": {countryCode: 27, trunkPrefix: "0", minLength: 9, maxLength: 9},
+}
+
+// regionsByCode maps country calling codes to their primary region.
+var regionsByCode = map[int]string{
+	1: "US", 44: "GB", 353: "IE", 49: "DE", 33: "FR", 34: "ES", 39: "IT",
+	31: "NL", 32: "BE", 41: "CH", 43: "AT", 46: "SE", 48: "PL", 351: "PT",
+	61: "AU", 64: "NZ", 91: "IN", 81: "JP", 65: "SG", 55: "BR", 52: "MX",
+	27: "ZA",
+}
+
+// Number is a parsed phone number.
+type Number struct {
+	// E164 is the number in E.164 format, e.g. "+14155551234".
+	E164 string
+
+	// CountryCode is the country calling code, e.g. 1.
+	CountryCode int
+
+	// NationalNumber is the national significant number, e.g.
+	// "4155551234".
+	NationalNumber string
+
+	// Region is the ISO 3166-1 region code if known, e.g. "US". Numbers
+	// in shared calling codes report the primary region.
+	Region string
+}
+
+// String returns the E.164 form.
+func (n Number) String() string {
+	return n.E164
+}
+
+// Parse parses a phone number in E.164 or international format, or in the
+// national format of defaultRegion (e.g. "(415) 555-1234" with "US"),
+// ignoring spaces and punctuation.
+func Parse(input, defaultRegion string) (Number, error) {
+	raw := strings.TrimSpace(input)
+	if raw == "" {
+		return Number{}, invalid(input, ReasonEmpty)
+	}
+
+	var b strings.Builder
+	for i, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case strings.ContainsRune(" \t-.()/\u00a0", r):
+		default:
+			return Number{}, invalid(input, ReasonInvalidCharacters)
+		}
+	}
+	s := b.String()
+
+	region := strings.ToUpper(defaultRegion)
+	switch {
+	case strings.HasPrefix(s, "+"):
+		return parseInternational(input, s[1:])
+	case strings.HasPrefix(s, "00"):
+		return parseInternational(input, s[2:])
+	case strings.HasPrefix(s, "011") && region != "" && regions[region].countryCode == 1:
+		return parseInternational(input, s[3:])
+	}
+
+	if region == "" {
+		return Number{}, invalid(input, ReasonUnknownRegion)
+	}
+	plan, ok := regions[region]
+	if !ok {
+		return Number{}, invalid(input, ReasonUnknownRegion)
+	}
+
+	// Drop the trunk prefix; "1" only when dialed, as in 1-415-555-1234
+	national := s
+	switch {
+	case plan.trunkPrefix == "0":
+		national = strings.TrimPrefix(national, "0")
+	case plan.trunkPrefix != "" && len(national) > plan.maxLength:
+		national = strings.TrimPrefix(national, plan.trunkPrefix)
+	}
+	return build(input, plan.countryCode, national, region)
+}
+
+// parseInternational parses digits that start with a country calling code.
+func parseInternational(input, digits string) (Number, error) {
+	if len(digits) < minE164Digits {
+		return Number{}, invalid(input, ReasonTooShort)
+	}
+	if len(digits) > maxE164Digits {
+		return Number{}, invalid(input, ReasonTooLong)
+	}
+	if digits[0] == '0' {
+		return Number{}, invalid(input, ReasonInvalidCountryCode)
+	}
+
+	for n := 1; n <= 3; n++ {
+		code, _ := strconv.Atoi(digits[:n])
+		if region, ok := regionsByCode[code]; ok {
+			return build(input, code, digits[n:], region)
+		}
+	}
+
+	// An unlisted calling code; only E.164 length can be checked
+	return Number{E164: "+" + digits}, nil
+}
+
+// build validates a national number and assembles a Number.
+func build(input string, countryCode int, national, region string) (Number, error) {
+	plan := regions[region]
+	if len(national) < plan.minLength {
+		return Number{}, invalid(input, ReasonTooShort)
+	}
+	if len(national) > plan.maxLength {
+		return Number{}, invalid(input, ReasonTooLong)
+	}
+
+	// North American numbers are NXX-NXX-XXXX
+	if countryCode == 1 && (national[0] < '2' || national[3] < '2') {
+		return Number{}, invalid(input, ReasonInvalidAreaCode)
+	}
+
+	e164 := "+" + strconv.Itoa(countryCode) + national
+	if len(e164)-1 > maxE164Digits {
+		return Number{}, invalid(input, ReasonTooLong)
+	}
+
+	return Number{
+		E164:           e164,
+		CountryCode:    countryCode,
+		NationalNumber: national,
+		Region:         region,
+	}, nil
+}
+
+// Normalize returns input as E.164. See Parse.
+func Normalize(input, defaultRegion string) (string, error) {
+	n, err := Parse(input, defaultRegion)
+	if err != nil {
+		return "", err
+	}
+	return n.E164, nil
+}
+
+// Kind is the kind of call target.
+type Kind string
+
+// Target kinds.
+const (
+	KindPhone  Kind = "phone"
+	KindSIP    Kind = "sip"
+	KindClient Kind = "client"
+)
+
+// Target is a validated call target.
+type Target struct {
+	Kind Kind
+
+	// Value is the normalized target to pass to Twilio: an E.164 number,
+	// a SIP URI or "client:<identity>".
+	Value string
+
+	// Number is set for phone targets.
+	Number Number
+}
+
+// String returns the normalized target.
+func (t Target) String() string {
+	return t.Value
+}
+
+// ParseTarget parses a phone number (see Parse), a SIP URI
+// ("sip:user@host") or a Twilio Client identity ("client:alice").
+func ParseTarget(input, defaultRegion string) (Target, error) {
+	s := strings.TrimSpace(input)
+	lower := strings.ToLower(s)
+
+	switch {
+	case strings.HasPrefix(lower, "sip:") || strings.HasPrefix(lower, "sips:"):
+		if err := validateSIPURI(s); err != nil {
+			return Target{}, invalid(input, ReasonInvalidSIPURI)
+		}
+		return Target{Kind: KindSIP, Value: s}, nil
+
+	case strings.HasPrefix(lower, "client:"):
+		identity := s[len("client:"):]
+		if !ValidClientIdentity(identity) {
+			return Target{}, invalid(input, ReasonInvalidClient)
+		}
+		return Target{Kind: KindClient, Value: "client:" + identity}, nil
+	}
+
+	n, err := Parse(input, defaultRegion)
+	if err != nil {
+		return Target{}, err
+	}
+	return Target{Kind: KindPhone, Value: n.E164, Number: n}, nil
+}
+
+// validateSIPURI checks a sip: or sips: URI of the form
+// user@host[:port][;params].
+func validateSIPURI(uri string) error {
+	_, rest, _ := strings.Cut(uri, ":")
+	if i := strings.IndexAny(rest, ";?"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	user, hostport, ok := strings.Cut(rest, "@")
+	if !ok || user == "" || strings.ContainsAny(user, " \t<>\"") {
+		return ErrInvalid
+	}
+
+	host := hostport
+	if h, port, err := net.SplitHostPort(hostport); err == nil {
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+			return ErrInvalid
+		}
+		host = h
+	}
+	if host == "" || strings.ContainsAny(host, " \t<>\"@/") {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// ValidClientIdentity reports whether identity is a valid Twilio Client
+// identity: 1 to 121 letters, digits, or '_', '-', '.', '@'.
+func ValidClientIdentity(identity string) bool {
+	if identity == "" || len(identity) > maxClientIdentity {
+		return false
+	}
+	for _, r := range identity {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("_-.@", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package phonenumber
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input  string
+		region string
+		want   string
+	}{
+		// E.164 and international formats ignore the region
+		{"+14155550100", "", "+14155550100"},
+		{"+1 (415) 555-0100", "GB", "+14155550100"},
+		{"+44 20 7946 0958", "US", "+442079460958"},
+		{"0044 20 7946 0958", "US", "+442079460958"},
+		{"011 44 20 7946 0958", "US", "+442079460958"},
+		{"+353 1 234 5678", "", "+35312345678"},
+		{"+49 30 123456", "", "+4930123456"},
+
+		// National formats
+		{"(415) 555-0100", "US", "+14155550100"},
+		{"415.555.0100", "us", "+14155550100"},
+		{"1-415-555-0100", "US", "+14155550100"},
+		{"(604) 555-0100", "CA", "+16045550100"},
+		{"020 7946 0958", "GB", "+442079460958"},
+		{"07700 900123", "GB", "+447700900123"},
+		{"01 23 45 67 89", "FR", "+33123456789"},
+		{"0412 345 678", "AU", "+61412345678"},
+		{"612 345 678", "ES", "+34612345678"},
+
+		// Unlisted calling codes are accepted in E.164 format
+		{"+8613800138000", "", "+8613800138000"},
+	}
+
+	for _, tt := range tests {
+		got, err := Normalize(tt.input, tt.region)
+		if err != nil {
+			t.Errorf("Normalize(%q, %q) error = %v", tt.input, tt.region, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q, %q) = %q, want %q", tt.input, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		input  string
+		region string
+		reason Reason
+	}{
+		{"", "US", ReasonEmpty},
+		{"   ", "US", ReasonEmpty},
+		{"415-555-CALL", "US", ReasonInvalidCharacters},
+		{"41+55550100", "US", ReasonInvalidCharacters},
+		{"+1415", "", ReasonTooShort},
+		{"+1234567890123456", "", ReasonTooLong},
+		{"+0123456789", "", ReasonInvalidCountryCode},
+		{"4155550100", "", ReasonUnknownRegion},
+		{"4155550100", "XX", ReasonUnknownRegion},
+		{"555-0100", "US", ReasonTooShort},
+		{"415555010012", "US", ReasonTooLong},
+		{"(115) 555-0100", "US", ReasonInvalidAreaCode},
+		{"(415) 155-0100", "US", ReasonInvalidAreaCode},
+		{"+1 015 555 0100", "", ReasonInvalidAreaCode},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input, tt.region)
+		var perr *Error
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q, %q) error = %v, want *Error", tt.input, tt.region, err)
+			continue
+		}
+		if perr.Reason != tt.reason {
+			t.Errorf("Parse(%q, %q) reason = %q, want %q", tt.input, tt.region, perr.Reason, tt.reason)
+		}
+		if !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q, %q) error does not match ErrInvalid", tt.input, tt.region)
+		}
+	}
+}
+
+func TestErrorMasksInput(t *testing.T) {
+	_, err := Parse("415555010012", "US")
+	if err == nil {
+		t.Fatal("Parse() error = nil")
+	}
+	if msg := err.Error(); strings.Contains(msg, "415555010012") || !strings.Contains(msg, "0012") {
+		t.Errorf("Parse() error = %q, want masked number", msg)
+	}
+}
+
+func TestParse(t *testing.T) {
+	n, err := Parse("020 7946 0958", "GB")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := Number{E164: "+442079460958", CountryCode: 44, NationalNumber: "2079460958", Region: "GB"}
+	if n != want {
+		t.Errorf("Parse() = %+v, want %+v", n, want)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		input string
+		kind  Kind
+		want  string
+	}{
+		{"(415) 555-0100", KindPhone, "+14155550100"},
+		{"sip:alice@example.com", KindSIP, "sip:alice@example.com"},
+		{"sips:alice@example.com:5061;transport=tls", KindSIP, "sips:alice@example.com:5061;transport=tls"},
+		{"client:alice", KindClient, "client:alice"},
+		{"Client:alice", KindClient, "client:alice"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTarget(tt.input, "US")
+		if err != nil {
+			t.Errorf("ParseTarget(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got.Kind != tt.kind || got.Value != tt.want {
+			t.Errorf("ParseTarget(%q) = %s %q, want %s %q", tt.input, got.Kind, got.Value, tt.kind, tt.want)
+		}
+	}
+
+	invalid := []struct {
+		input  string
+		reason Reason
+	}{
+		{"sip:example.com", ReasonInvalidSIPURI},
+		{"sip:alice@", ReasonInvalidSIPURI},
+		{"client:", ReasonInvalidClient},
+		{"client:bad identity", ReasonInvalidClient},
+	}
+	for _, tt := range invalid {
+		_, err := ParseTarget(tt.input, "US")
+		var perr *Error
+		if !errors.As(err, &perr) || perr.Reason != tt.reason {
+			t.Errorf("ParseTarget(%q) error = %v, want reason %q", tt.input, err, tt.reason)
+		}
+	}
+}
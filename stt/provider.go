@@ -11,9 +11,11 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/agentplexus/omnivoice-twilio/logging"
 	"github.com/agentplexus/omnivoice/stt"
 )
 
@@ -28,6 +30,7 @@ type Provider struct {
 	defaultLanguage string
 	speechModel     string
 	profanityFilter bool
+	logger          *slog.Logger
 }
 
 // Option configures the Provider.
@@ -37,6 +40,7 @@ type options struct {
 	language        string
 	speechModel     string
 	profanityFilter bool
+	logger          *slog.Logger
 }
 
 // WithLanguage sets the default language.
@@ -61,6 +65,13 @@ func WithProfanityFilter(enabled bool) Option {
 	}
 }
 
+// WithLogger sets the logger for transcription sessions.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
 // New creates a new Twilio STT provider.
 func New(opts ...Option) (*Provider, error) {
 	cfg := &options{
@@ -76,6 +87,7 @@ func New(opts ...Option) (*Provider, error) {
 		defaultLanguage: cfg.language,
 		speechModel:     cfg.speechModel,
 		profanityFilter: cfg.profanityFilter,
+		logger:          logging.Logger(cfg.logger),
 	}, nil
 }
 
@@ -103,6 +115,8 @@ func (p *Provider) TranscribeURL(ctx context.Context, url string, config stt.Tra
 // TranscribeStream creates a streaming transcription session.
 // This works with Twilio's real-time transcription on Media Streams.
 func (p *Provider) TranscribeStream(ctx context.Context, config stt.TranscriptionConfig) (io.WriteCloser, <-chan stt.StreamEvent, error) {
+	p.logger.DebugContext(ctx, "transcription stream opened", slog.String("language", config.Language))
+
 	eventCh := make(chan stt.StreamEvent, 100)
 	writer := &streamWriter{
 		provider: p,
@@ -149,6 +163,7 @@ func (w *streamWriter) Close() error {
 	if !w.closed {
 		w.closed = true
 		close(w.eventCh)
+		w.provider.logger.Debug("transcription stream closed")
 	}
 	return nil
 }
@@ -0,0 +1,149 @@
+// Package tracing defines the spans this module reports and how their
+// trace context is carried across Twilio.
+//
+// Packages accept a Tracer with a WithTracer option and trace nothing by
+// default. Implement Tracer to forward to OpenTelemetry or another backend.
+//
+// Each call has a span, SpanCall, started when the call is placed or its
+// incoming webhook arrives and ended when the call ends. REST requests
+// (SpanREST) and Media Streams (SpanStream) are its children, and the
+// agent pipeline (SpanAgent) is a child of the stream it runs on. Twilio
+// does not forward trace headers, so the call's trace context is added to
+// the <Stream> TwiML as <Parameter> elements named after the W3C Trace
+// Context headers and extracted again from the stream's start message.
+//
+// With OpenTelemetry, Inject and Extract map directly onto a propagator:
+//
+//	func (t otelTracer) Inject(ctx context.Context, carrier map[string]string) {
+//	    otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(carrier))
+//	}
+package tracing
+
+import "context"
+
+// Span names.
+const (
+	// SpanCall spans a call from creation to end. Its CallSid is the
+	// AttrCallSID attribute.
+	SpanCall = "twilio.call"
+
+	// SpanREST spans a REST API request.
+	SpanREST = "twilio.rest"
+
+	// SpanStream spans a Media Stream connection, including resumes.
+	SpanStream = "twilio.stream"
+
+	// SpanAgent spans an agent session connected to a Media Stream.
+	SpanAgent = "twilio.agent"
+)
+
+// Attribute keys.
+const (
+	AttrCallSID        = "twilio.call_sid"
+	AttrStreamSID      = "twilio.stream_sid"
+	AttrAccountSID     = "twilio.account_sid"
+	AttrDirection      = "twilio.call.direction"
+	AttrEndReason      = "twilio.call.end_reason"
+	AttrDisconnect     = "twilio.stream.disconnect_reason"
+	AttrErrorCode      = "twilio.error_code"
+	AttrHTTPMethod     = "http.request.method"
+	AttrHTTPStatusCode = "http.response.status_code"
+	AttrURLTemplate    = "url.template"
+)
+
+// Carrier keys of the W3C Trace Context format.
+const (
+	TraceParent = "traceparent"
+	TraceState  = "tracestate"
+)
+
+// Span is an operation being traced. Implementations must be safe for
+// concurrent use.
+type Span interface {
+	// SetAttribute sets an attribute. Values are strings, bools, ints or
+	// float64s.
+	SetAttribute(key string, value any)
+
+	// AddEvent records that something happened during the span.
+	AddEvent(name string)
+
+	// RecordError records err and marks the span as failed.
+	RecordError(err error)
+
+	// End ends the span. Calls after the first have no effect.
+	End()
+}
+
+// Tracer starts spans and propagates their context. Implementations must
+// be safe for concurrent use.
+type Tracer interface {
+	// Start starts a span that is a child of the span in ctx, if any, and
+	// returns a context carrying it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+
+	// Inject adds the trace context of ctx to carrier.
+	Inject(ctx context.Context, carrier map[string]string)
+
+	// Extract returns ctx carrying the trace context in carrier, so spans
+	// started from it are children of the remote span.
+	Extract(ctx context.Context, carrier map[string]string) context.Context
+}
+
+// Noop returns a Tracer that records nothing. It still passes trace
+// context from Extract through to Inject, so a trace started upstream
+// survives the TwiML round trip.
+func Noop() Tracer {
+	return noop{}
+}
+
+type noop struct{}
+
+type carrierKey struct{}
+
+func (noop) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noop{}
+}
+
+func (noop) Inject(ctx context.Context, carrier map[string]string) {
+	remote, _ := ctx.Value(carrierKey{}).(map[string]string)
+	for key, value := range remote {
+		carrier[key] = value
+	}
+}
+
+func (noop) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	remote := make(map[string]string, 2)
+	for _, key := range []string{TraceParent, TraceState} {
+		if value := carrier[key]; value != "" {
+			remote[key] = value
+		}
+	}
+	if len(remote) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, carrierKey{}, remote)
+}
+
+func (noop) SetAttribute(string, any) {}
+func (noop) AddEvent(string)          {}
+func (noop) RecordError(error)        {}
+func (noop) End()                     {}
+
+// OrNoop returns tracer, or Noop if it is nil.
+func OrNoop(tracer Tracer) Tracer {
+	if tracer == nil {
+		return Noop()
+	}
+	return tracer
+}
+
+// Carrier returns the trace context of ctx as a carrier, or nil if it has
+// none.
+func Carrier(ctx context.Context, tracer Tracer) map[string]string {
+	carrier := make(map[string]string)
+	tracer.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
@@ -0,0 +1,248 @@
+package transport
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentplexus/omnivoice/transport"
+)
+
+// EventDTMFSequence indicates a collected DTMF sequence completed.
+// The event Data is a *DTMFSequence.
+const EventDTMFSequence transport.EventType = "dtmf_sequence"
+
+// DefaultInterDigitTimeout is the inter-digit timeout used when
+// DTMFCollectorConfig.InterDigitTimeout is zero.
+const DefaultInterDigitTimeout = 5 * time.Second
+
+// DTMF sequence completion reasons.
+const (
+	DTMFReasonNumDigits         = "num_digits"
+	DTMFReasonFinishOnKey       = "finish_on_key"
+	DTMFReasonInterDigitTimeout = "inter_digit_timeout"
+	DTMFReasonTimeout           = "timeout"
+)
+
+// DTMFCollectorConfig configures DTMF digit collection on a connection.
+// NumDigits and FinishOnKey follow the semantics of TwiML <Gather>.
+type DTMFCollectorConfig struct {
+	// NumDigits completes the sequence once this many digits are collected.
+	// Zero means no limit.
+	NumDigits int
+
+	// FinishOnKey lists the keys that complete the sequence (e.g. "#").
+	// The key itself is not included in the digits. Empty disables it.
+	FinishOnKey string
+
+	// InterDigitTimeout completes the sequence when no digit arrives for
+	// this long. Defaults to DefaultInterDigitTimeout.
+	InterDigitTimeout time.Duration
+
+	// Timeout completes the sequence this long after its first digit,
+	// regardless of input. Zero means no limit.
+	Timeout time.Duration
+}
+
+// DTMFSequence is a completed group of DTMF digits.
+type DTMFSequence struct {
+	// Digits are the collected digits, excluding any finish key.
+	Digits string
+
+	// FinishKey is the key that completed the sequence, if any.
+	FinishKey string
+
+	// Reason is why the sequence completed (one of the DTMFReason constants).
+	Reason string
+
+	// StartTime is when the first key was pressed.
+	StartTime time.Time
+
+	// EndTime is when the sequence completed.
+	EndTime time.Time
+}
+
+// dtmfCollector groups individual digits into sequences.
+type dtmfCollector struct {
+	config DTMFCollectorConfig
+	emit   func(*DTMFSequence)
+
+	mu         sync.Mutex
+	digits     strings.Builder
+	startTime  time.Time
+	interTimer *time.Timer
+	totalTimer *time.Timer
+	generation int
+}
+
+func newDTMFCollector(config DTMFCollectorConfig, emit func(*DTMFSequence)) *dtmfCollector {
+	if config.InterDigitTimeout <= 0 {
+		config.InterDigitTimeout = DefaultInterDigitTimeout
+	}
+	return &dtmfCollector{config: config, emit: emit}
+}
+
+// add records a digit and completes the sequence if a limit is reached.
+func (d *dtmfCollector) add(digit string) {
+	d.mu.Lock()
+
+	if digit != "" && strings.Contains(d.config.FinishOnKey, digit) {
+		seq := d.finishLocked(DTMFReasonFinishOnKey)
+		seq.FinishKey = digit
+		d.mu.Unlock()
+		d.emit(seq)
+		return
+	}
+
+	if d.digits.Len() == 0 {
+		d.startTime = time.Now()
+		if d.config.Timeout > 0 {
+			gen := d.generation
+			d.totalTimer = time.AfterFunc(d.config.Timeout, func() {
+				d.expire(gen, DTMFReasonTimeout)
+			})
+		}
+	}
+	d.digits.WriteString(digit)
+
+	if d.config.NumDigits > 0 && d.digits.Len() >= d.config.NumDigits {
+		seq := d.finishLocked(DTMFReasonNumDigits)
+		d.mu.Unlock()
+		d.emit(seq)
+		return
+	}
+
+	if d.interTimer != nil {
+		d.interTimer.Stop()
+	}
+	gen := d.generation
+	d.interTimer = time.AfterFunc(d.config.InterDigitTimeout, func() {
+		d.expire(gen, DTMFReasonInterDigitTimeout)
+	})
+
+	d.mu.Unlock()
+}
+
+// expire completes the sequence from a timer, unless it already completed.
+func (d *dtmfCollector) expire(gen int, reason string) {
+	d.mu.Lock()
+	if gen != d.generation || d.digits.Len() == 0 {
+		d.mu.Unlock()
+		return
+	}
+	seq := d.finishLocked(reason)
+	d.mu.Unlock()
+	d.emit(seq)
+}
+
+// finishLocked builds the completed sequence and resets the collector.
+// It must be called with d.mu held.
+func (d *dtmfCollector) finishLocked(reason string) *DTMFSequence {
+	now := time.Now()
+	start := d.startTime
+	if d.digits.Len() == 0 {
+		start = now
+	}
+
+	seq := &DTMFSequence{
+		Digits:    d.digits.String(),
+		Reason:    reason,
+		StartTime: start,
+		EndTime:   now,
+	}
+
+	d.resetLocked()
+	return seq
+}
+
+// resetLocked discards pending digits and timers.
+func (d *dtmfCollector) resetLocked() {
+	if d.interTimer != nil {
+		d.interTimer.Stop()
+		d.interTimer = nil
+	}
+	if d.totalTimer != nil {
+		d.totalTimer.Stop()
+		d.totalTimer = nil
+	}
+	d.digits.Reset()
+	d.startTime = time.Time{}
+	d.generation++
+}
+
+// stop discards pending digits without emitting a sequence.
+func (d *dtmfCollector) stop() {
+	d.mu.Lock()
+	d.resetLocked()
+	d.mu.Unlock()
+}
+
+// CollectDTMF groups incoming digits into sequences according to config.
+// Each completed sequence is emitted as an EventDTMFSequence event and
+// passed to the provider's OnDTMFSequence handler. Individual EventDTMF
+// events are still delivered. Calling CollectDTMF again replaces the
+// configuration and discards any partial sequence.
+func (c *Connection) CollectDTMF(config DTMFCollectorConfig) {
+	collector := newDTMFCollector(config, c.emitDTMFSequence)
+
+	c.mu.Lock()
+	prev := c.dtmf
+	c.dtmf = collector
+	c.mu.Unlock()
+
+	if prev != nil {
+		prev.stop()
+	}
+}
+
+// StopCollectingDTMF disables DTMF sequence collection, discarding any
+// partial sequence.
+func (c *Connection) StopCollectingDTMF() {
+	c.mu.Lock()
+	prev := c.dtmf
+	c.dtmf = nil
+	c.mu.Unlock()
+
+	if prev != nil {
+		prev.stop()
+	}
+}
+
+// CollectingDTMF reports whether DTMF sequence collection is enabled.
+func (c *Connection) CollectingDTMF() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dtmf != nil
+}
+
+// collectDTMF feeds a received digit to the active collector, if any.
+func (c *Connection) collectDTMF(digit string) {
+	c.mu.RLock()
+	collector := c.dtmf
+	c.mu.RUnlock()
+
+	if collector != nil {
+		collector.add(digit)
+	}
+}
+
+// emitDTMFSequence delivers a completed sequence to listeners.
+func (c *Connection) emitDTMFSequence(seq *DTMFSequence) {
+	c.emit(transport.Event{Type: EventDTMFSequence, Data: seq})
+
+	c.provider.mu.RLock()
+	handler := c.provider.dtmfSequenceHandler
+	c.provider.mu.RUnlock()
+
+	if handler != nil {
+		handler(c, seq)
+	}
+}
+
+// OnDTMFSequence sets the handler for completed DTMF sequences on
+// connections with collection enabled via Connection.CollectDTMF.
+func (p *Provider) OnDTMFSequence(handler func(conn transport.Connection, seq *DTMFSequence)) {
+	p.mu.Lock()
+	p.dtmfSequenceHandler = handler
+	p.mu.Unlock()
+}
@@ -5,16 +5,57 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
-
+	"sync/atomic"
+	"time"
+
+	"github.com/agentplexus/omnivoice-twilio/credentials"
+	"github.com/agentplexus/omnivoice-twilio/internal/client"
+	"github.com/agentplexus/omnivoice-twilio/logging"
+	"github.com/agentplexus/omnivoice-twilio/metrics"
+	"github.com/agentplexus/omnivoice-twilio/phonenumber"
+	"github.com/agentplexus/omnivoice-twilio/tracing"
 	"github.com/agentplexus/omnivoice/transport"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// startTimeout bounds how long a new WebSocket may take to send its
+	// start message.
+	startTimeout = 10 * time.Second
+
+	// redirectTimeout bounds how long a connection waits for Twilio to
+	// reconnect the stream after a TwiML redirect (e.g. SendDTMF).
+	redirectTimeout = 15 * time.Second
+)
+
+// DisconnectReason describes why a connection ended. It is the Data of
+// EventDisconnected.
+type DisconnectReason string
+
+// Disconnect reasons.
+const (
+	// DisconnectStopped means Twilio stopped the stream, usually because
+	// the call ended.
+	DisconnectStopped DisconnectReason = "stopped"
+
+	// DisconnectDropped means the WebSocket closed without a stop
+	// message, or a redirected stream never reconnected.
+	DisconnectDropped DisconnectReason = "dropped"
+
+	// DisconnectClosed means the connection was closed locally.
+	DisconnectClosed DisconnectReason = "closed"
+)
+
 // Verify interface compliance at compile time.
 var (
 	_ transport.Transport          = (*Provider)(nil)
@@ -23,21 +64,49 @@ var (
 
 // Provider implements transport.Transport using Twilio Media Streams.
 type Provider struct {
-	accountSID string
-	authToken  string
-
-	mu          sync.RWMutex
-	connections map[string]*Connection
-	listeners   map[string]chan transport.Connection
-	dtmfHandler func(conn transport.Connection, digit string)
+	accountSID       string
+	authToken        string
+	apiKeySID        string
+	apiKeySecret     string
+	credentials      credentials.Provider
+	validateSigs     bool
+	region           string
+	edge             string
+	streamURL        string
+	earlyAudioFrames int
+	logger           *slog.Logger
+	meter            metrics.Meter
+	metrics          streamMetrics
+	tracer           tracing.Tracer
+
+	mu                  sync.RWMutex
+	client              *client.Client
+	connections         map[string]*Connection
+	redirects           map[string]*Connection // keyed by call SID
+	listeners           map[string]chan transport.Connection
+	dtmfHandler         func(conn transport.Connection, digit string)
+	dtmfSequenceHandler func(conn transport.Connection, seq *DTMFSequence)
+	startHandlers       map[int]func(conn *Connection)
+	nextStartHandler    int
 }
 
 // Option configures the Provider.
 type Option func(*options)
 
 type options struct {
-	accountSID string
-	authToken  string
+	accountSID       string
+	authToken        string
+	apiKeySID        string
+	apiKeySecret     string
+	credentials      credentials.Provider
+	validateSigs     bool
+	region           string
+	edge             string
+	streamURL        string
+	earlyAudioFrames int
+	logger           *slog.Logger
+	meter            metrics.Meter
+	tracer           tracing.Tracer
 }
 
 // WithAccountSID sets the Twilio Account SID.
@@ -54,6 +123,90 @@ func WithAuthToken(token string) Option {
 	}
 }
 
+// WithAPIKey authenticates REST requests with an API key instead of the
+// auth token. The account SID is still required.
+func WithAPIKey(sid, secret string) Option {
+	return func(o *options) {
+		o.apiKeySID = sid
+		o.apiKeySecret = secret
+	}
+}
+
+// WithCredentials supplies credentials from provider, which is consulted
+// for every REST request and webhook signature check. It takes precedence
+// over WithAuthToken and WithAPIKey.
+func WithCredentials(provider credentials.Provider) Option {
+	return func(o *options) {
+		o.credentials = provider
+	}
+}
+
+// WithSignatureValidation makes HandleWebSocket reject upgrade requests
+// whose X-Twilio-Signature does not match the primary or secondary auth
+// token.
+func WithSignatureValidation() Option {
+	return func(o *options) {
+		o.validateSigs = true
+	}
+}
+
+// WithRegion sets the Twilio Region (e.g. "ie1") for REST requests. The
+// account credentials must belong to that region.
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+
+// WithEdge sets the Twilio Edge location (e.g. "dublin") for REST
+// requests.
+func WithEdge(edge string) Option {
+	return func(o *options) {
+		o.edge = edge
+	}
+}
+
+// WithLogger sets the logger for stream lifecycle and errors. Phone
+// numbers and secrets are redacted as described in package logging.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithMeter reports stream counts, audio frames and mark latency to meter.
+func WithMeter(meter metrics.Meter) Option {
+	return func(o *options) {
+		o.meter = meter
+	}
+}
+
+// WithTracer traces each stream with a span that is a child of the span
+// whose context was passed in the stream's custom parameters.
+func WithTracer(tracer tracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithStreamURL sets the public WebSocket URL Twilio connects Media Streams to.
+// It is used when a call must be reconnected to the stream after a TwiML
+// redirect. If unset, the URL is derived from the incoming WebSocket request.
+func WithStreamURL(url string) Option {
+	return func(o *options) {
+		o.streamURL = url
+	}
+}
+
+// WithEarlyAudioHold makes new connections hold up to maxFrames inbound
+// audio frames that would otherwise be dropped, until Connection.ReleaseAudio
+// is called. Use it when streams may start before anything reads from them.
+func WithEarlyAudioHold(maxFrames int) Option {
+	return func(o *options) {
+		o.earlyAudioFrames = maxFrames
+	}
+}
+
 // New creates a new Twilio Media Streams transport provider.
 func New(opts ...Option) (*Provider, error) {
 	cfg := &options{}
@@ -62,10 +215,24 @@ func New(opts ...Option) (*Provider, error) {
 	}
 
 	return &Provider{
-		accountSID:  cfg.accountSID,
-		authToken:   cfg.authToken,
-		connections: make(map[string]*Connection),
-		listeners:   make(map[string]chan transport.Connection),
+		accountSID:       cfg.accountSID,
+		authToken:        cfg.authToken,
+		apiKeySID:        cfg.apiKeySID,
+		apiKeySecret:     cfg.apiKeySecret,
+		credentials:      cfg.credentials,
+		validateSigs:     cfg.validateSigs,
+		region:           cfg.region,
+		edge:             cfg.edge,
+		streamURL:        cfg.streamURL,
+		earlyAudioFrames: cfg.earlyAudioFrames,
+		logger:           logging.Logger(cfg.logger),
+		meter:            metrics.OrNoop(cfg.meter),
+		metrics:          newStreamMetrics(metrics.OrNoop(cfg.meter)),
+		tracer:           tracing.OrNoop(cfg.tracer),
+		connections:      make(map[string]*Connection),
+		redirects:        make(map[string]*Connection),
+		listeners:        make(map[string]chan transport.Connection),
+		startHandlers:    make(map[int]func(conn *Connection)),
 	}, nil
 }
 
@@ -93,7 +260,19 @@ func (p *Provider) Listen(ctx context.Context, addr string) (<-chan transport.Co
 
 // HandleWebSocket handles an incoming WebSocket connection from Twilio.
 // This should be called from your HTTP WebSocket handler.
+//
+// The connection is delivered to the listener once Twilio sends the stream's
+// start message. A stream that reconnects a call after a redirect (see
+// SendDTMF) resumes the existing connection instead of creating a new one.
 func (p *Provider) HandleWebSocket(w http.ResponseWriter, r *http.Request, listenerPath string) error {
+	if p.validateSigs {
+		if err := p.validateSignature(r.Context(), r.Header.Get("X-Twilio-Signature"), "wss://"+r.Host+r.URL.RequestURI()); err != nil {
+			p.logger.Warn("rejected media stream request", logging.Error(err))
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return err
+		}
+	}
+
 	upgrader := websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool { return true },
 	}
@@ -103,25 +282,123 @@ func (p *Provider) HandleWebSocket(w http.ResponseWriter, r *http.Request, liste
 		return fmt.Errorf("websocket upgrade failed: %w", err)
 	}
 
-	conn := &Connection{
-		id:       "", // Will be set from start message
-		wsConn:   wsConn,
-		provider: p,
-		events:   make(chan transport.Event, 100),
-		audioIn:  newAudioWriter(),
-		audioOut: newAudioReader(),
-		done:     make(chan struct{}),
+	streamURL := p.streamURL
+	if streamURL == "" {
+		streamURL = "wss://" + r.Host + r.URL.RequestURI()
 	}
 
-	// Start read/write loops
-	go conn.readLoop()
-	go conn.writeLoop()
+	go p.serve(wsConn, listenerPath, streamURL)
 
-	// Notify listener
-	p.mu.RLock()
+	return nil
+}
+
+// validateSignature checks the X-Twilio-Signature of a WebSocket upgrade
+// request, which Twilio signs with the stream URL.
+func (p *Provider) validateSignature(ctx context.Context, signature, requestURL string) error {
+	c, err := p.apiClient()
+	if err != nil {
+		return err
+	}
+
+	err = credentials.ValidateSignature(ctx, c.CredentialsProvider(), signature, requestURL, nil)
+	if errors.Is(err, credentials.ErrInvalidSignature) && p.streamURL != "" && p.streamURL != requestURL {
+		// Behind a proxy the request may not carry the public host
+		err = credentials.ValidateSignature(ctx, c.CredentialsProvider(), signature, p.streamURL, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to validate stream request: %w", err)
+	}
+	return nil
+}
+
+// serve waits for the stream's start message, binds the WebSocket to a
+// connection and runs the read loop until the stream ends.
+func (p *Provider) serve(wsConn *websocket.Conn, listenerPath, streamURL string) {
+	start, err := readStart(wsConn)
+	if err != nil {
+		p.logger.Warn("media stream ended before start", logging.Error(err))
+		_ = wsConn.Close()
+		return
+	}
+	p.serveStarted(wsConn, start, listenerPath, streamURL)
+}
+
+// serveStarted binds a WebSocket whose start message has been read to a
+// connection and runs the read loop until the stream ends.
+func (p *Provider) serveStarted(wsConn *websocket.Conn, start *startMessage, listenerPath, streamURL string) {
+	p.mu.Lock()
+	conn, resumed := p.redirects[start.CallSID]
+	if resumed {
+		delete(p.redirects, start.CallSID)
+	}
+	p.mu.Unlock()
+
+	if resumed {
+		conn.resume(wsConn, start)
+		conn.logger.Info("media stream resumed", slog.String(logging.KeyStreamSID, start.StreamSID))
+		conn.span.AddEvent("resumed")
+		conn.readLoop(wsConn)
+		return
+	}
+
+	conn = &Connection{
+		id:           start.StreamSID,
+		streamSID:    start.StreamSID,
+		callSID:      start.CallSID,
+		customParams: start.CustomParams,
+		streamURL:    streamURL,
+		wsConn:       wsConn,
+		provider:     p,
+		events:       make(chan transport.Event, 100),
+		done:         make(chan struct{}),
+		remoteAddr:   wsConn.RemoteAddr(),
+		logger: p.logger.With(
+			slog.String(logging.KeyCallSID, start.CallSID),
+			slog.String(logging.KeyAccountSID, start.AccountSID),
+		),
+	}
+	ctx, span := p.tracer.Start(p.tracer.Extract(context.Background(), start.CustomParams), tracing.SpanStream)
+	span.SetAttribute(tracing.AttrCallSID, start.CallSID)
+	span.SetAttribute(tracing.AttrStreamSID, start.StreamSID)
+	span.SetAttribute(tracing.AttrAccountSID, start.AccountSID)
+	conn.span = span
+	conn.trace = tracing.Carrier(ctx, p.tracer)
+
+	conn.audioIn = newAudioWriter(func(total uint64) {
+		p.metrics.dropped.Add(1, directionOutbound)
+		if total == 1 {
+			conn.logger.Warn("dropping outbound audio; stream is not keeping up")
+		}
+	})
+	conn.audioOut = newAudioReader(p.earlyAudioFrames, func(total uint64) {
+		p.metrics.dropped.Add(1, directionInbound)
+		if total == 1 {
+			conn.logger.Warn("dropping inbound audio; nothing is reading AudioOut fast enough")
+		}
+	})
+	conn.logger.Info("media stream started", slog.String(logging.KeyStreamSID, start.StreamSID))
+	p.metrics.started.Add(1)
+	p.metrics.active.Add(1)
+
+	p.mu.Lock()
+	p.connections[conn.streamSID] = conn
 	listener, ok := p.listeners[listenerPath]
-	p.mu.RUnlock()
+	handlers := make([]func(conn *Connection), 0, len(p.startHandlers))
+	for _, handler := range p.startHandlers {
+		handlers = append(handlers, handler)
+	}
+	p.mu.Unlock()
+
+	conn.emit(transport.Event{Type: transport.EventConnected})
+	conn.emit(transport.Event{Type: transport.EventAudioStarted})
+
+	go conn.writeLoop()
+
+	for _, handler := range handlers {
+		handler(conn)
+	}
 
+	// Notify listener
 	if ok {
 		select {
 		case listener <- conn:
@@ -129,7 +406,36 @@ func (p *Provider) HandleWebSocket(w http.ResponseWriter, r *http.Request, liste
 		}
 	}
 
-	return nil
+	conn.readLoop(wsConn)
+}
+
+// readStart reads messages until the stream's start message arrives.
+func readStart(wsConn *websocket.Conn) (*startMessage, error) {
+	if err := wsConn.SetReadDeadline(time.Now().Add(startTimeout)); err != nil {
+		return nil, err
+	}
+
+	for {
+		_, data, err := wsConn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		var msg mediaMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+
+		switch msg.Event {
+		case "start":
+			if msg.Start == nil {
+				return nil, fmt.Errorf("start message has no payload")
+			}
+			return msg.Start, wsConn.SetReadDeadline(time.Time{})
+		case "stop":
+			return nil, fmt.Errorf("stream stopped before start")
+		}
+	}
 }
 
 // Connect initiates an outbound connection (not typically used for Media Streams).
@@ -140,10 +446,12 @@ func (p *Provider) Connect(ctx context.Context, addr string, config transport.Co
 // Close shuts down the transport.
 func (p *Provider) Close() error {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
+	conns := make([]*Connection, 0, len(p.connections)+len(p.redirects))
 	for _, conn := range p.connections {
-		_ = conn.Close()
+		conns = append(conns, conn)
+	}
+	for _, conn := range p.redirects {
+		conns = append(conns, conn)
 	}
 
 	for _, ch := range p.listeners {
@@ -151,14 +459,47 @@ func (p *Provider) Close() error {
 	}
 
 	p.connections = make(map[string]*Connection)
+	p.redirects = make(map[string]*Connection)
 	p.listeners = make(map[string]chan transport.Connection)
+	p.mu.Unlock()
+
+	// Connection.Close takes the provider lock, so close outside of it.
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
 
 	return nil
 }
 
-// SendDTMF sends DTMF tones (not directly supported via Media Streams).
+// SendDTMF sends DTMF tones on the call behind a Media Streams connection.
+//
+// Media Streams cannot carry DTMF, so the call is updated via the REST API
+// with TwiML that plays the digits and reconnects the stream. The connection
+// (and anything reading from or writing to it) survives the redirect.
 func (p *Provider) SendDTMF(conn transport.Connection, digits string) error {
-	return fmt.Errorf("DTMF sending not supported via Media Streams; use TwiML <Play> verb")
+	c, ok := conn.(*Connection)
+	if !ok {
+		return fmt.Errorf("connection is not a Twilio Media Streams connection")
+	}
+	return c.SendDTMF(context.Background(), digits)
+}
+
+// OnStreamStart registers a handler called for every new Media Stream
+// connection once its start message (and so its call SID) is known.
+// Streams resumed after a redirect are not reported again. The returned
+// function removes the handler.
+func (p *Provider) OnStreamStart(handler func(conn *Connection)) func() {
+	p.mu.Lock()
+	id := p.nextStartHandler
+	p.nextStartHandler++
+	p.startHandlers[id] = handler
+	p.mu.Unlock()
+
+	return func() {
+		p.mu.Lock()
+		delete(p.startHandlers, id)
+		p.mu.Unlock()
+	}
 }
 
 // OnDTMF sets the DTMF handler.
@@ -168,9 +509,35 @@ func (p *Provider) OnDTMF(handler func(conn transport.Connection, digit string))
 	p.mu.Unlock()
 }
 
-// Transfer transfers the call (requires Twilio API call).
+// Transfer dials target and connects the caller to it, ending the Media
+// Stream. Target is an E.164 number, a sip: URI or a client: identity.
 func (p *Provider) Transfer(conn transport.Connection, target string) error {
-	return fmt.Errorf("transfer not implemented; use CallSystem to update the call")
+	tc, ok := conn.(*Connection)
+	if !ok {
+		return fmt.Errorf("not a Twilio connection")
+	}
+
+	twiml, err := DialTwiML(target)
+	if err != nil {
+		return err
+	}
+
+	c, err := p.apiClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	tc.mu.RLock()
+	callSID := tc.callSID
+	tc.mu.RUnlock()
+
+	if _, err := c.UpdateCall(tc.TraceContext(ctx), callSID, &client.UpdateCallParams{Twiml: twiml}); err != nil {
+		return fmt.Errorf("failed to transfer call: %w", err)
+	}
+	return nil
 }
 
 // Hold places the call on hold.
@@ -183,21 +550,90 @@ func (p *Provider) Unhold(conn transport.Connection) error {
 	return fmt.Errorf("unhold not implemented; use CallSystem to update the call")
 }
 
+// apiClient returns the REST client, creating it on first use.
+func (p *Provider) apiClient() (*client.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client != nil {
+		return p.client, nil
+	}
+
+	c, err := client.New(&client.Config{
+		AccountSID:   p.accountSID,
+		AuthToken:    p.authToken,
+		APIKeySID:    p.apiKeySID,
+		APIKeySecret: p.apiKeySecret,
+		Credentials:  p.credentials,
+		Region:       p.region,
+		Edge:         p.edge,
+		Logger:       p.logger,
+		Meter:        p.meter,
+		Tracer:       p.tracer,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Twilio client: %w", err)
+	}
+	p.client = c
+	return c, nil
+}
+
+// Audio directions for metrics.
+const (
+	directionInbound  = "inbound"
+	directionOutbound = "outbound"
+)
+
+// streamMetrics are the transport's instruments.
+type streamMetrics struct {
+	started metrics.Counter
+	active  metrics.Gauge
+	frames  metrics.Counter
+	dropped metrics.Counter
+	markRTT metrics.Histogram
+}
+
+// newStreamMetrics creates the transport's instruments.
+func newStreamMetrics(meter metrics.Meter) streamMetrics {
+	return streamMetrics{
+		started: meter.Counter(metrics.StreamsStarted, "Media Stream connections started."),
+		active:  meter.Gauge(metrics.StreamsActive, "Open Media Stream connections."),
+		frames:  meter.Counter(metrics.AudioFrames, "Media Stream audio frames.", metrics.LabelDirection),
+		dropped: meter.Counter(metrics.AudioFramesDropped, "Media Stream audio frames dropped because a buffer was full.", metrics.LabelDirection),
+		markRTT: meter.Histogram(metrics.MarkRoundTrip, "Seconds between sending a mark and Twilio reporting it played.", metrics.DefaultBuckets),
+	}
+}
+
 // Connection implements transport.Connection for Twilio Media Streams.
 type Connection struct {
-	id         string
-	streamSID  string
-	callSID    string
-	wsConn     *websocket.Conn
-	provider   *Provider
-	events     chan transport.Event
-	audioIn    *audioWriter
-	audioOut   *audioReader
-	done       chan struct{}
-	mu         sync.RWMutex
-	closed     bool
-	closeOnce  sync.Once
-	remoteAddr net.Addr
+	id            string
+	streamSID     string
+	callSID       string
+	customParams  map[string]string
+	streamURL     string
+	wsConn        *websocket.Conn
+	provider      *Provider
+	events        chan transport.Event
+	audioIn       *audioWriter
+	audioOut      *audioReader
+	done          chan struct{}
+	mu            sync.RWMutex
+	writeMu       sync.Mutex
+	closed        bool
+	closeOnce     sync.Once
+	redirecting   bool
+	redirectTimer *time.Timer
+	dtmf          *dtmfCollector
+	observers     map[int]func(transport.Event)
+	audioObs      map[int]func([]byte)
+	nextObserver  int
+	disconnected  bool
+	stopped       bool // Twilio sent "stop" for the current stream
+	remoteAddr    net.Addr
+	logger        *slog.Logger
+	marks         map[string]time.Time // sent marks awaiting playback
+	span          tracing.Span
+	trace         map[string]string // trace context of span
 }
 
 // ID returns the connection identifier (stream SID).
@@ -214,6 +650,25 @@ func (c *Connection) CallSID() string {
 	return c.callSID
 }
 
+// CustomParameters returns the custom <Parameter> values from the stream's
+// start message.
+func (c *Connection) CustomParameters() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	params := make(map[string]string, len(c.customParams))
+	for k, v := range c.customParams {
+		params[k] = v
+	}
+	return params
+}
+
+// TraceContext returns ctx carrying the stream's span, so spans started
+// from it are children of the stream.
+func (c *Connection) TraceContext(ctx context.Context) context.Context {
+	return c.provider.tracer.Extract(ctx, c.trace)
+}
+
 // AudioIn returns a writer for sending audio to Twilio.
 func (c *Connection) AudioIn() io.WriteCloser {
 	return c.audioIn
@@ -224,6 +679,19 @@ func (c *Connection) AudioOut() io.Reader {
 	return c.audioOut
 }
 
+// ReadAudio reads like AudioOut().Read, but returns ctx.Err() without
+// consuming audio if ctx is done first, so a consumer can stop reading
+// without losing a frame meant for the next one.
+func (c *Connection) ReadAudio(ctx context.Context, p []byte) (int, error) {
+	return c.audioOut.read(ctx, p)
+}
+
+// ReleaseAudio stops holding early audio (see WithEarlyAudioHold). Audio
+// already held is still delivered by AudioOut.
+func (c *Connection) ReleaseAudio() {
+	c.audioOut.release()
+}
+
 // Events returns a channel for transport events.
 func (c *Connection) Events() <-chan transport.Event {
 	return c.events
@@ -231,24 +699,281 @@ func (c *Connection) Events() <-chan transport.Event {
 
 // Close closes the connection.
 func (c *Connection) Close() error {
+	return c.closeWithReason(DisconnectClosed)
+}
+
+// closeWithReason closes the connection, reporting reason with the
+// EventDisconnected delivered to observers.
+func (c *Connection) closeWithReason(reason DisconnectReason) error {
 	c.closeOnce.Do(func() {
 		c.mu.Lock()
 		c.closed = true
+		if c.redirectTimer != nil {
+			c.redirectTimer.Stop()
+		}
+		if c.dtmf != nil {
+			c.dtmf.stop()
+		}
+		wsConn := c.wsConn
+		streamSID := c.streamSID
+		callSID := c.callSID
 		c.mu.Unlock()
 
 		close(c.done)
 		_ = c.audioIn.Close()
 		c.audioOut.close()
 		close(c.events)
-		_ = c.wsConn.Close()
+		_ = wsConn.Close()
 
 		c.provider.mu.Lock()
-		delete(c.provider.connections, c.streamSID)
+		delete(c.provider.connections, streamSID)
+		if c.provider.redirects[callSID] == c {
+			delete(c.provider.redirects, callSID)
+		}
 		c.provider.mu.Unlock()
+
+		c.provider.metrics.active.Add(-1)
+		c.logger.Info("media stream closed",
+			slog.String(logging.KeyStreamSID, streamSID),
+			slog.String(logging.KeyReason, string(reason)),
+			slog.Uint64("dropped_inbound_frames", c.audioOut.dropped.Load()),
+			slog.Uint64("dropped_outbound_frames", c.audioIn.dropped.Load()),
+		)
+		c.span.SetAttribute(tracing.AttrDisconnect, string(reason))
+		c.span.End()
+
+		c.notify(transport.Event{Type: transport.EventDisconnected, Data: reason})
 	})
 	return nil
 }
 
+// SendDTMF plays DTMF digits on the call and reconnects the stream.
+// Valid digits are 0-9, '*', '#', and 'w' for a half-second pause.
+func (c *Connection) SendDTMF(ctx context.Context, digits string) error {
+	if err := validateDigits(digits); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return fmt.Errorf("connection closed")
+	}
+	if c.redirecting {
+		c.mu.Unlock()
+		return fmt.Errorf("call redirect already in progress")
+	}
+	c.redirecting = true
+	callSID := c.callSID
+	twiml := buildReconnectTwiML(&PlayElement{Digits: digits}, c.streamURL, c.customParams)
+	c.mu.Unlock()
+
+	api, err := c.provider.apiClient()
+	if err != nil {
+		c.cancelRedirect()
+		return err
+	}
+
+	c.provider.mu.Lock()
+	c.provider.redirects[callSID] = c
+	c.provider.mu.Unlock()
+
+	if _, err := api.UpdateCall(c.TraceContext(ctx), callSID, &client.UpdateCallParams{Twiml: twiml}); err != nil {
+		c.cancelRedirect()
+		return fmt.Errorf("failed to send DTMF: %w", err)
+	}
+
+	return nil
+}
+
+// cancelRedirect abandons a redirect that never reached Twilio.
+func (c *Connection) cancelRedirect() {
+	c.mu.Lock()
+	c.redirecting = false
+	callSID := c.callSID
+	c.mu.Unlock()
+
+	c.provider.mu.Lock()
+	if c.provider.redirects[callSID] == c {
+		delete(c.provider.redirects, callSID)
+	}
+	c.provider.mu.Unlock()
+}
+
+// resume binds a reconnected stream's WebSocket to this connection.
+func (c *Connection) resume(wsConn *websocket.Conn, start *startMessage) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		_ = wsConn.Close()
+		return
+	}
+	if c.redirectTimer != nil {
+		c.redirectTimer.Stop()
+		c.redirectTimer = nil
+	}
+	oldStreamSID := c.streamSID
+	oldConn := c.wsConn
+	c.wsConn = wsConn
+	c.streamSID = start.StreamSID
+	c.remoteAddr = wsConn.RemoteAddr()
+	c.redirecting = false
+	c.mu.Unlock()
+
+	// The previous stream normally stops before the new one starts; make
+	// sure it is gone either way.
+	if oldConn != wsConn {
+		_ = oldConn.Close()
+	}
+
+	c.provider.mu.Lock()
+	delete(c.provider.connections, oldStreamSID)
+	c.provider.connections[start.StreamSID] = c
+	c.provider.mu.Unlock()
+}
+
+// detach handles the end of a WebSocket's read loop. The connection is
+// closed unless it is waiting for Twilio to reconnect the stream.
+func (c *Connection) detach(wsConn *websocket.Conn) {
+	c.mu.Lock()
+	current := c.wsConn == wsConn
+	if current && c.redirecting && !c.closed {
+		c.redirectTimer = time.AfterFunc(redirectTimeout, func() { _ = c.closeWithReason(DisconnectDropped) })
+		c.mu.Unlock()
+		_ = wsConn.Close()
+		return
+	}
+	reason := DisconnectDropped
+	if c.stopped {
+		reason = DisconnectStopped
+	}
+	c.mu.Unlock()
+
+	if current {
+		_ = c.closeWithReason(reason)
+		return
+	}
+	_ = wsConn.Close()
+}
+
+// isRedirecting reports whether the connection is waiting for a redirect.
+func (c *Connection) isRedirecting() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.redirecting
+}
+
+// OnEvent registers an observer that is called synchronously for every
+// event, in addition to delivery on Events(). Unlike the channel, observers
+// are never starved by other readers. An EventDisconnected is always
+// observed, even when the connection is closed locally. The returned
+// function removes the observer.
+func (c *Connection) OnEvent(fn func(transport.Event)) func() {
+	c.mu.Lock()
+	if c.observers == nil {
+		c.observers = make(map[int]func(transport.Event))
+	}
+	id := c.nextObserver
+	c.nextObserver++
+	c.observers[id] = fn
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		delete(c.observers, id)
+		c.mu.Unlock()
+	}
+}
+
+// OnAudio registers an observer that is called synchronously with each
+// frame of caller audio (8 kHz μ-law) as it arrives, without consuming it
+// from AudioOut. Observers must not retain or modify the frame. The
+// returned function removes the observer.
+func (c *Connection) OnAudio(fn func([]byte)) func() {
+	c.mu.Lock()
+	if c.audioObs == nil {
+		c.audioObs = make(map[int]func([]byte))
+	}
+	id := c.nextObserver
+	c.nextObserver++
+	c.audioObs[id] = fn
+	c.mu.Unlock()
+
+	return func() {
+		c.mu.Lock()
+		delete(c.audioObs, id)
+		c.mu.Unlock()
+	}
+}
+
+// notifyAudio calls the registered audio observers with a frame.
+func (c *Connection) notifyAudio(frame []byte) {
+	c.mu.RLock()
+	if len(c.audioObs) == 0 {
+		c.mu.RUnlock()
+		return
+	}
+	observers := make([]func([]byte), 0, len(c.audioObs))
+	for _, fn := range c.audioObs {
+		observers = append(observers, fn)
+	}
+	c.mu.RUnlock()
+
+	for _, fn := range observers {
+		fn(frame)
+	}
+}
+
+// notify calls the registered observers with event.
+func (c *Connection) notify(event transport.Event) {
+	c.mu.Lock()
+	if event.Type == transport.EventDisconnected {
+		if c.disconnected {
+			c.mu.Unlock()
+			return
+		}
+		c.disconnected = true
+	}
+	observers := make([]func(transport.Event), 0, len(c.observers))
+	for _, fn := range c.observers {
+		observers = append(observers, fn)
+	}
+	c.mu.Unlock()
+
+	for _, fn := range observers {
+		fn(event)
+	}
+}
+
+// emit delivers an event to observers and, without blocking, to the
+// events channel; it is dropped from the channel if the channel is full
+// or the connection is closed.
+func (c *Connection) emit(event transport.Event) {
+	c.notify(event)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return
+	}
+	select {
+	case c.events <- event:
+	default:
+	}
+}
+
+// writeJSON writes a message to the current WebSocket.
+func (c *Connection) writeJSON(msg any) error {
+	c.mu.RLock()
+	wsConn := c.wsConn
+	c.mu.RUnlock()
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return wsConn.WriteJSON(msg)
+}
+
 // RemoteAddr returns the remote address.
 func (c *Connection) RemoteAddr() net.Addr {
 	c.mu.RLock()
@@ -303,20 +1028,16 @@ type dtmfMessage struct {
 }
 
 // readLoop reads messages from the WebSocket.
-func (c *Connection) readLoop() {
-	defer func() { _ = c.Close() }()
+func (c *Connection) readLoop(wsConn *websocket.Conn) {
+	defer c.detach(wsConn)
 
 	for {
-		select {
-		case <-c.done:
-			return
-		default:
-		}
-
-		_, data, err := c.wsConn.ReadMessage()
+		_, data, err := wsConn.ReadMessage()
 		if err != nil {
-			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-				c.events <- transport.Event{Type: transport.EventError, Error: err}
+			if !c.isRedirecting() && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.logger.Warn("media stream read failed", logging.Error(err))
+				c.span.RecordError(err)
+				c.emit(transport.Event{Type: transport.EventError, Error: err})
 			}
 			return
 		}
@@ -327,24 +1048,6 @@ func (c *Connection) readLoop() {
 		}
 
 		switch msg.Event {
-		case "connected":
-			// Connection established
-			c.events <- transport.Event{Type: transport.EventConnected}
-
-		case "start":
-			if msg.Start != nil {
-				c.mu.Lock()
-				c.streamSID = msg.Start.StreamSID
-				c.callSID = msg.Start.CallSID
-				c.mu.Unlock()
-
-				c.provider.mu.Lock()
-				c.provider.connections[c.streamSID] = c
-				c.provider.mu.Unlock()
-
-				c.events <- transport.Event{Type: transport.EventAudioStarted}
-			}
-
 		case "media":
 			if msg.Media != nil && msg.Media.Payload != "" {
 				// Decode base64 audio
@@ -352,16 +1055,18 @@ func (c *Connection) readLoop() {
 				if err != nil {
 					continue
 				}
+				c.provider.metrics.frames.Add(1, directionInbound)
+				c.notifyAudio(audio)
 				// Write to audio output
 				c.audioOut.write(audio)
 			}
 
 		case "dtmf":
 			if msg.DTMF != nil {
-				c.events <- transport.Event{
+				c.emit(transport.Event{
 					Type: transport.EventDTMF,
 					Data: msg.DTMF.Digit,
-				}
+				})
 
 				c.provider.mu.RLock()
 				handler := c.provider.dtmfHandler
@@ -370,15 +1075,28 @@ func (c *Connection) readLoop() {
 				if handler != nil {
 					handler(c, msg.DTMF.Digit)
 				}
+
+				c.collectDTMF(msg.DTMF.Digit)
 			}
 
 		case "stop":
-			c.events <- transport.Event{Type: transport.EventAudioStopped}
-			c.events <- transport.Event{Type: transport.EventDisconnected}
+			// A redirected call stops this stream and starts a new one.
+			if c.isRedirecting() {
+				return
+			}
+			c.logger.Info("media stream stopped by Twilio")
+			c.mu.Lock()
+			c.stopped = true
+			c.mu.Unlock()
+			c.emit(transport.Event{Type: transport.EventAudioStopped})
+			c.emit(transport.Event{Type: transport.EventDisconnected, Data: DisconnectStopped})
 			return
 
 		case "mark":
-			// Mark event - can be used for synchronization
+			// Twilio echoes a mark once the audio sent before it played
+			if msg.Mark != nil {
+				c.markPlayed(msg.Mark.Name)
+			}
 		}
 	}
 }
@@ -389,27 +1107,41 @@ func (c *Connection) writeLoop() {
 		select {
 		case <-c.done:
 			return
-		case audio := <-c.audioIn.ch:
+		case audio, ok := <-c.audioIn.ch:
+			if !ok {
+				return
+			}
+
+			c.mu.RLock()
+			closed := c.closed
+			redirecting := c.redirecting
+			streamSID := c.streamSID
+			c.mu.RUnlock()
+
+			// Audio written while the stream is being redirected is dropped.
+			if closed || redirecting {
+				continue
+			}
+
 			// Encode audio to base64
 			encoded := base64.StdEncoding.EncodeToString(audio)
 
 			msg := map[string]any{
 				"event":     "media",
-				"streamSid": c.streamSID,
+				"streamSid": streamSID,
 				"media": map[string]string{
 					"payload": encoded,
 				},
 			}
 
-			c.mu.RLock()
-			closed := c.closed
-			c.mu.RUnlock()
-
-			if !closed {
-				if err := c.wsConn.WriteJSON(msg); err != nil {
-					return
+			if err := c.writeJSON(msg); err != nil {
+				if c.isRedirecting() {
+					continue
 				}
+				c.logger.Warn("media stream write failed", logging.Error(err))
+				return
 			}
+			c.provider.metrics.frames.Add(1, directionOutbound)
 		}
 	}
 }
@@ -418,21 +1150,171 @@ func (c *Connection) writeLoop() {
 func (c *Connection) SendMark(name string) error {
 	msg := map[string]any{
 		"event":     "mark",
-		"streamSid": c.streamSID,
+		"streamSid": c.ID(),
 		"mark": map[string]string{
 			"name": name,
 		},
 	}
-	return c.wsConn.WriteJSON(msg)
+
+	c.mu.Lock()
+	if c.marks == nil {
+		c.marks = make(map[string]time.Time)
+	}
+	c.marks[name] = time.Now()
+	c.mu.Unlock()
+
+	return c.writeJSON(msg)
+}
+
+// markPlayed records the round trip of a mark sent with SendMark.
+func (c *Connection) markPlayed(name string) {
+	c.mu.Lock()
+	sent, ok := c.marks[name]
+	delete(c.marks, name)
+	c.mu.Unlock()
+
+	if ok {
+		c.provider.metrics.markRTT.Observe(time.Since(sent).Seconds())
+	}
 }
 
-// Clear clears the audio buffer.
+// Clear clears the audio buffer, discarding audio queued locally as well as
+// audio already sent to Twilio but not yet played.
 func (c *Connection) Clear() error {
+	c.audioIn.drain()
+
 	msg := map[string]any{
 		"event":     "clear",
-		"streamSid": c.streamSID,
+		"streamSid": c.ID(),
 	}
-	return c.wsConn.WriteJSON(msg)
+	return c.writeJSON(msg)
+}
+
+// PlayElement represents a TwiML <Play> element.
+type PlayElement struct {
+	XMLName xml.Name `xml:"Play"`
+	Digits  string   `xml:"digits,attr,omitempty"`
+	URL     string   `xml:",chardata"`
+}
+
+// ParameterElement represents a TwiML <Parameter> element.
+type ParameterElement struct {
+	XMLName xml.Name `xml:"Parameter"`
+	Name    string   `xml:"name,attr"`
+	Value   string   `xml:"value,attr"`
+}
+
+// StreamElement represents a TwiML <Stream> element.
+type StreamElement struct {
+	XMLName    xml.Name `xml:"Stream"`
+	URL        string   `xml:"url,attr"`
+	Parameters []ParameterElement
+}
+
+// ConnectElement represents a TwiML <Connect> element.
+type ConnectElement struct {
+	XMLName xml.Name `xml:"Connect"`
+	Stream  *StreamElement
+}
+
+// ResponseElement represents a TwiML <Response> element.
+type ResponseElement struct {
+	XMLName xml.Name `xml:"Response"`
+	Verbs   []any
+	Connect *ConnectElement
+}
+
+// DialElement represents a TwiML <Dial> verb with a single noun.
+type DialElement struct {
+	XMLName xml.Name `xml:"Dial"`
+	Noun    any
+}
+
+// NumberElement represents a TwiML <Number> noun.
+type NumberElement struct {
+	XMLName xml.Name `xml:"Number"`
+	Number  string   `xml:",chardata"`
+}
+
+// ClientElement represents a TwiML <Client> noun.
+type ClientElement struct {
+	XMLName  xml.Name `xml:"Client"`
+	Identity string   `xml:",chardata"`
+}
+
+// SipElement represents a TwiML <Sip> noun.
+type SipElement struct {
+	XMLName  xml.Name `xml:"Sip"`
+	Username string   `xml:"username,attr,omitempty"`
+	Password string   `xml:"password,attr,omitempty"`
+	URI      string   `xml:",chardata"`
+}
+
+// DialTwiML returns TwiML that dials target: an E.164 number (<Number>),
+// a sip: URI (<Sip>) or a client: identity (<Client>).
+func DialTwiML(target string) (string, error) {
+	parsed, err := phonenumber.ParseTarget(target, "")
+	if err != nil {
+		return "", err
+	}
+
+	var noun any
+	switch parsed.Kind {
+	case phonenumber.KindClient:
+		noun = &ClientElement{Identity: strings.TrimPrefix(parsed.Value, "client:")}
+	case phonenumber.KindSIP:
+		noun = &SipElement{URI: parsed.Value}
+	default:
+		noun = &NumberElement{Number: parsed.Value}
+	}
+
+	response := &ResponseElement{Verbs: []any{&DialElement{Noun: noun}}}
+	xmlBytes, err := xml.MarshalIndent(response, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("failed to build dial TwiML: %w", err)
+	}
+	return xml.Header + string(xmlBytes), nil
+}
+
+// buildReconnectTwiML creates TwiML that runs verb and then reconnects the
+// call to the Media Stream with the original custom parameters.
+func buildReconnectTwiML(verb any, streamURL string, params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stream := &StreamElement{URL: streamURL}
+	for _, name := range names {
+		stream.Parameters = append(stream.Parameters, ParameterElement{Name: name, Value: params[name]})
+	}
+
+	response := &ResponseElement{
+		Verbs:   []any{verb},
+		Connect: &ConnectElement{Stream: stream},
+	}
+
+	xmlBytes, err := xml.MarshalIndent(response, "", "    ")
+	if err != nil {
+		return fmt.Sprintf(`<Response><Connect><Stream url="%s"/></Connect></Response>`, streamURL)
+	}
+
+	return xml.Header + string(xmlBytes)
+}
+
+// validateDigits checks that digits only contains characters <Play digits>
+// accepts.
+func validateDigits(digits string) error {
+	if digits == "" {
+		return fmt.Errorf("no DTMF digits given")
+	}
+	if i := strings.IndexFunc(digits, func(r rune) bool {
+		return !strings.ContainsRune("0123456789*#wW", r)
+	}); i >= 0 {
+		return fmt.Errorf("invalid DTMF digit %q", digits[i])
+	}
+	return nil
 }
 
 // audioWriter implements io.WriteCloser for sending audio.
@@ -440,11 +1322,23 @@ type audioWriter struct {
 	ch     chan []byte
 	closed bool
 	mu     sync.Mutex
+
+	dropped atomic.Uint64
+	onDrop  func(total uint64)
 }
 
-func newAudioWriter() *audioWriter {
+// drop counts a dropped frame.
+func (w *audioWriter) drop() {
+	total := w.dropped.Add(1)
+	if w.onDrop != nil {
+		w.onDrop(total)
+	}
+}
+
+func newAudioWriter(onDrop func(total uint64)) *audioWriter {
 	return &audioWriter{
-		ch: make(chan []byte, 100),
+		ch:     make(chan []byte, 100),
+		onDrop: onDrop,
 	}
 }
 
@@ -467,6 +1361,7 @@ func (w *audioWriter) Write(p []byte) (n int, err error) {
 		// Buffer full, drop oldest
 		select {
 		case <-w.ch:
+			w.drop()
 		default:
 		}
 		w.ch <- data
@@ -474,6 +1369,23 @@ func (w *audioWriter) Write(p []byte) (n int, err error) {
 	}
 }
 
+// drain discards queued audio.
+func (w *audioWriter) drain() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for {
+		select {
+		case _, ok := <-w.ch:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
 func (w *audioWriter) Close() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -486,19 +1398,49 @@ func (w *audioWriter) Close() error {
 }
 
 // audioReader implements io.Reader for receiving audio.
+//
+// Frames that do not fit in the channel are normally dropped. While the
+// reader is holding, they are queued in held (up to maxHeld frames) so no
+// early audio is lost before a consumer attaches.
 type audioReader struct {
 	ch     chan []byte
 	buffer []byte
 	mu     sync.Mutex
+
+	heldMu  sync.Mutex
+	held    [][]byte
+	holding bool
+	maxHeld int
+	closed  bool
+
+	dropped atomic.Uint64
+	onDrop  func(total uint64)
+}
+
+// drop counts a dropped frame.
+func (r *audioReader) drop() {
+	total := r.dropped.Add(1)
+	if r.onDrop != nil {
+		r.onDrop(total)
+	}
 }
 
-func newAudioReader() *audioReader {
+func newAudioReader(maxHeld int, onDrop func(total uint64)) *audioReader {
 	return &audioReader{
-		ch: make(chan []byte, 100),
+		ch:      make(chan []byte, 100),
+		holding: maxHeld > 0,
+		maxHeld: maxHeld,
+		onDrop:  onDrop,
 	}
 }
 
 func (r *audioReader) Read(p []byte) (n int, err error) {
+	return r.read(context.Background(), p)
+}
+
+// read reads like Read, but returns ctx.Err() without consuming a frame if
+// ctx is done before one arrives.
+func (r *audioReader) read(ctx context.Context, p []byte) (n int, err error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -509,11 +1451,29 @@ func (r *audioReader) Read(p []byte) (n int, err error) {
 		return n, nil
 	}
 
-	// Wait for new data
-	data, ok := <-r.ch
+	// Frames in the channel are older than held frames
+	var data []byte
+	ok := true
+	select {
+	case data, ok = <-r.ch:
+	default:
+		if data = r.popHeld(); data == nil {
+			// Wait for new data
+			select {
+			case data, ok = <-r.ch:
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+	}
 	if !ok {
 		return 0, io.EOF
 	}
+	if err := ctx.Err(); err != nil {
+		// Keep the frame for the next reader
+		r.buffer = data
+		return 0, err
+	}
 
 	n = copy(p, data)
 	if n < len(data) {
@@ -522,14 +1482,61 @@ func (r *audioReader) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+// popHeld removes and returns the oldest held frame, or nil.
+func (r *audioReader) popHeld() []byte {
+	r.heldMu.Lock()
+	defer r.heldMu.Unlock()
+
+	if len(r.held) == 0 {
+		return nil
+	}
+	data := r.held[0]
+	r.held = r.held[1:]
+	return data
+}
+
 func (r *audioReader) write(data []byte) {
-	select {
-	case r.ch <- data:
-	default:
+	r.heldMu.Lock()
+	defer r.heldMu.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	// Keep frames in order behind any that are already held
+	if len(r.held) == 0 {
+		select {
+		case r.ch <- data:
+			return
+		default:
+		}
+	}
+
+	if !r.holding && len(r.held) == 0 {
 		// Buffer full, drop
+		r.drop()
+		return
+	}
+	if len(r.held) >= r.maxHeld {
+		r.held = r.held[1:]
+		r.drop()
 	}
+	r.held = append(r.held, data)
+}
+
+// release stops holding; frames already held are still delivered.
+func (r *audioReader) release() {
+	r.heldMu.Lock()
+	r.holding = false
+	r.heldMu.Unlock()
 }
 
 func (r *audioReader) close() {
-	close(r.ch)
+	r.heldMu.Lock()
+	defer r.heldMu.Unlock()
+
+	if !r.closed {
+		r.closed = true
+		close(r.ch)
+	}
 }
@@ -0,0 +1,87 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// StreamInfo identifies a Media Stream from its start message.
+type StreamInfo struct {
+	AccountSID   string
+	CallSID      string
+	StreamSID    string
+	CustomParams map[string]string
+}
+
+// StreamRouter serves Media Streams for several Providers on one URL, for
+// example one per tenant in a multi-tenant server. Twilio does not pass
+// query parameters to stream URLs, so the Provider is chosen once the
+// stream's start message has been read.
+type StreamRouter struct {
+	route func(info StreamInfo) *Provider
+}
+
+// NewStreamRouter creates a router that hands each stream to the Provider
+// returned by route. Streams for which route returns nil are closed.
+func NewStreamRouter(route func(info StreamInfo) *Provider) *StreamRouter {
+	return &StreamRouter{route: route}
+}
+
+// HandleWebSocket handles an incoming WebSocket connection from Twilio, like
+// Provider.HandleWebSocket. Signatures are validated after routing, for
+// Providers created with WithSignatureValidation.
+func (rt *StreamRouter) HandleWebSocket(w http.ResponseWriter, r *http.Request, listenerPath string) error {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+
+	wsConn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return fmt.Errorf("websocket upgrade failed: %w", err)
+	}
+
+	// The request must not be used once this handler returns
+	signature := r.Header.Get("X-Twilio-Signature")
+	requestURL := "wss://" + r.Host + r.URL.RequestURI()
+
+	go rt.serve(wsConn, listenerPath, signature, requestURL)
+
+	return nil
+}
+
+// serve reads the start message, routes the stream and hands it to the
+// chosen Provider.
+func (rt *StreamRouter) serve(wsConn *websocket.Conn, listenerPath, signature, requestURL string) {
+	start, err := readStart(wsConn)
+	if err != nil {
+		_ = wsConn.Close()
+		return
+	}
+
+	p := rt.route(StreamInfo{
+		AccountSID:   start.AccountSID,
+		CallSID:      start.CallSID,
+		StreamSID:    start.StreamSID,
+		CustomParams: start.CustomParams,
+	})
+	if p == nil {
+		_ = wsConn.Close()
+		return
+	}
+
+	if p.validateSigs {
+		if err := p.validateSignature(context.Background(), signature, requestURL); err != nil {
+			_ = wsConn.Close()
+			return
+		}
+	}
+
+	streamURL := p.streamURL
+	if streamURL == "" {
+		streamURL = requestURL
+	}
+	p.serveStarted(wsConn, start, listenerPath, streamURL)
+}
@@ -11,8 +11,10 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"log/slog"
 	"sync"
 
+	"github.com/agentplexus/omnivoice-twilio/logging"
 	"github.com/agentplexus/omnivoice/tts"
 )
 
@@ -23,6 +25,7 @@ var _ tts.Provider = (*Provider)(nil)
 type Provider struct {
 	defaultVoice    string
 	defaultLanguage string
+	logger          *slog.Logger
 
 	mu          sync.RWMutex
 	voicesCache []tts.Voice
@@ -34,6 +37,7 @@ type Option func(*options)
 type options struct {
 	voice    string
 	language string
+	logger   *slog.Logger
 }
 
 // WithVoice sets the default voice.
@@ -50,6 +54,13 @@ func WithLanguage(language string) Option {
 	}
 }
 
+// WithLogger sets the logger for synthesis requests. Text is never logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
 // New creates a new Twilio TTS provider.
 func New(opts ...Option) (*Provider, error) {
 	cfg := &options{
@@ -63,6 +74,7 @@ func New(opts ...Option) (*Provider, error) {
 	return &Provider{
 		defaultVoice:    cfg.voice,
 		defaultLanguage: cfg.language,
+		logger:          logging.Logger(cfg.logger),
 	}, nil
 }
 
@@ -77,6 +89,7 @@ func (p *Provider) Name() string {
 func (p *Provider) Synthesize(ctx context.Context, text string, config tts.SynthesisConfig) (*tts.SynthesisResult, error) {
 	// Generate TwiML
 	twiml := p.generateTwiML(text, config)
+	p.logger.DebugContext(ctx, "synthesized speech TwiML", slog.String("voice", config.VoiceID), slog.Int("characters", len(text)))
 
 	// Return TwiML as "audio" - this is a special case for Twilio
 	// The caller should use this TwiML with Twilio's API
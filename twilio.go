@@ -14,6 +14,8 @@
 //
 //	TWILIO_ACCOUNT_SID - Your Twilio Account SID
 //	TWILIO_AUTH_TOKEN  - Your Twilio Auth Token
+//	TWILIO_REGION      - Twilio Region for data residency, e.g. "ie1"
+//	TWILIO_EDGE        - Twilio Edge location, e.g. "dublin"
 //
 // # Quick Start
 //
@@ -29,6 +31,8 @@
 //	tr, _ := transport.New()
 package twilio
 
+import "strings"
+
 // Version is the SDK version.
 const Version = "0.1.0"
 
@@ -37,14 +41,80 @@ const ProviderName = "twilio"
 
 // Twilio API constants.
 const (
-	// DefaultAPIBaseURL is the Twilio REST API base URL.
+	// DefaultAPIBaseURL is the Twilio REST API base URL in the default
+	// region. See APIBaseURL for other regions.
 	DefaultAPIBaseURL = "https://api.twilio.com/2010-04-01"
 
-	// DefaultMediaStreamURL is the WebSocket URL format for Media Streams.
+	// DefaultMediaStreamURL is the WebSocket URL format for Media Streams in
+	// the default region. See MediaStreamURL for other regions.
 	// Format: wss://media-stream.twilio.com/v1/Accounts/{AccountSid}/Calls/{CallSid}/Media
 	DefaultMediaStreamURL = "wss://media-stream.twilio.com"
 )
 
+// Twilio Regions, where data is processed and stored. Each non-US region
+// has its own auth tokens and API keys.
+const (
+	RegionUS1 = "us1" // United States (default)
+	RegionIE1 = "ie1" // Ireland
+	RegionAU1 = "au1" // Australia
+)
+
+// Twilio Edge locations, where traffic enters Twilio's network.
+const (
+	EdgeAshburn   = "ashburn"
+	EdgeUmatilla  = "umatilla"
+	EdgeDublin    = "dublin"
+	EdgeFrankfurt = "frankfurt"
+	EdgeSaoPaulo  = "sao-paulo"
+	EdgeSingapore = "singapore"
+	EdgeSydney    = "sydney"
+	EdgeTokyo     = "tokyo"
+)
+
+// defaultEdges is the edge used for a region when none is given.
+var defaultEdges = map[string]string{
+	RegionUS1: EdgeAshburn,
+	RegionIE1: EdgeDublin,
+	RegionAU1: EdgeSydney,
+}
+
+// Hostname returns the hostname of a Twilio product ("api", "lookups",
+// "media-stream") for a region and edge, e.g. "api.dublin.ie1.twilio.com".
+// With neither set it returns the global hostname, e.g. "api.twilio.com". A
+// region without an edge uses the region's default edge, and an edge
+// without a region uses RegionUS1.
+func Hostname(product, region, edge string) string {
+	region = strings.ToLower(region)
+	edge = strings.ToLower(edge)
+
+	switch {
+	case region == "" && edge == "":
+		return product + ".twilio.com"
+	case region == "":
+		region = RegionUS1
+	case edge == "":
+		edge = defaultEdges[region]
+	}
+
+	parts := []string{product}
+	if edge != "" {
+		parts = append(parts, edge)
+	}
+	parts = append(parts, region, "twilio.com")
+	return strings.Join(parts, ".")
+}
+
+// APIBaseURL returns the REST API base URL for a region and edge.
+func APIBaseURL(region, edge string) string {
+	return "https://" + Hostname("api", region, edge) + "/2010-04-01"
+}
+
+// MediaStreamURL returns the Media Streams WebSocket URL for a region and
+// edge.
+func MediaStreamURL(region, edge string) string {
+	return "wss://" + Hostname("media-stream", region, edge)
+}
+
 // Audio format constants for Media Streams.
 const (
 	// AudioEncodingMulaw is the μ-law encoding (8-bit, 8kHz).